@@ -0,0 +1,221 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AdminHandler returns an http.Handler that allows for the global logging
+// state (the global log mode, file log modes and tracepoints) to be
+// inspected and reconfigured at runtime. Like net/http/pprof, it's to be
+// mounted by the library user; unlike it, nothing is registered
+// automatically (say, with http.DefaultServeMux). Authenticating requests is
+// left up to the user.
+//
+//	mux.Handle("/debug/log/", http.StripPrefix("/debug/log", log.AdminHandler()))
+//
+// The endpoints exposed (relative to where the handler is mounted) are as
+// follows, each of which responds with the resulting logging state as JSON:
+//
+//	GET    /                     Retrieve the logging state
+//	GET    /mode                 Retrieve the logging state
+//	PUT    /mode                 SetGlobalLogMode, request body of the form info|warn
+//	GET    /files                Retrieve the logging state
+//	PUT    /files/<fname>        SetFileLogMode, request body of the form debug
+//	DELETE /files/<fname>        ResetFileLogMode
+//	GET    /tracepoints          Retrieve the logging state
+//	PUT    /tracepoints/<tp>     SetTracePoint, where <tp> is of the form fname.go:42
+//	DELETE /tracepoints/<tp>     ResetTracePoint
+//	GET    /output               Retrieve the logging state, see AdminOutput
+//	PUT    /output/dir           Output.SetDir, request body of the form /path/to/dir
+//	PUT    /output/stderr        Output.SetToStderr, request body of the form true
+//	POST   /recorder             Logger.DumpFlightRecorder, see AdminFlightRecorder
+//
+// For example:
+//
+//	$ curl -X PUT -d 'debug' host:port/debug/log/files/store.go
+//	{
+//	  "mode": "info|warn|error",
+//	  "files": {
+//	    "store.go": "debug"
+//	  },
+//	  "tracepoints": []
+//	}
+func AdminHandler(options ...adminOption) http.Handler {
+	h := &adminHandler{}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+type adminOption func(*adminHandler)
+
+// AdminOutput configures the admin handler to additionally expose the
+// provided Output, allowing for the log directory and logging to standard
+// error to be reconfigured at runtime.
+func AdminOutput(o *Output) adminOption {
+	return func(h *adminHandler) {
+		h.output = o
+	}
+}
+
+// AdminFlightRecorder configures the admin handler to additionally allow for
+// the entries retained by the provided Logger's flight recorder (see
+// FlightRecorder) to be dumped on demand.
+func AdminFlightRecorder(l *Logger) adminOption {
+	return func(h *adminHandler) {
+		h.recorder = l
+	}
+}
+
+// AdminState is the logging state as exposed by AdminHandler.
+type AdminState struct {
+	Mode        Mode            `json:"mode"`        // See SetGlobalLogMode
+	Files       map[string]Mode `json:"files"`       // See SetFileLogMode
+	TracePoints []string        `json:"tracepoints"` // See SetTracePoint
+
+	Output   *AdminOutputState   `json:"output,omitempty"`   // See AdminOutput
+	Recorder *AdminRecorderState `json:"recorder,omitempty"` // See AdminFlightRecorder
+}
+
+// AdminOutputState is the state of the Output exposed by AdminHandler, if
+// any.
+type AdminOutputState struct {
+	Dir      string `json:"dir"`
+	ToStderr bool   `json:"stderr"`
+}
+
+// AdminRecorderState is the state of the flight recorder exposed by
+// AdminHandler, if any.
+type AdminRecorderState struct {
+	Pending int `json:"pending"` // Entries retained that weren't written out
+	Dumped  int `json:"dumped"`  // Entries written out by the request, if any
+}
+
+type adminHandler struct {
+	output   *Output // Output exposed through the handler, optional
+	recorder *Logger // Logger whose flight recorder is exposed, optional
+}
+
+var tracePointRegex = regexp.MustCompile(`^[^:/]+:[\d]+$`)
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	resource, arg := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		resource, arg = path[:i], path[i+1:]
+	}
+
+	var err error
+	var dumped int
+	switch {
+	case r.Method == http.MethodGet && arg == "" &&
+		(resource == "" || resource == "mode" || resource == "files" || resource == "tracepoints" ||
+			(resource == "output" && h.output != nil)):
+		// Nothing to do, we write out the logging state below.
+
+	case r.Method == http.MethodPut && resource == "mode" && arg == "":
+		var m Mode
+		if m, err = readMode(r); err == nil {
+			SetGlobalLogMode(m)
+		}
+
+	case r.Method == http.MethodPut && resource == "files" && arg != "":
+		var m Mode
+		if m, err = readMode(r); err == nil {
+			SetFileLogMode(arg, m)
+		}
+
+	case r.Method == http.MethodDelete && resource == "files" && arg != "":
+		ResetFileLogMode(arg)
+
+	case (r.Method == http.MethodPut || r.Method == http.MethodDelete) && resource == "tracepoints" && arg != "":
+		if !tracePointRegex.MatchString(arg) {
+			err = errors.New(
+				fmt.Sprintf("Improperly formatted tracepoint: %s, expected fname.go:line", arg))
+		} else if r.Method == http.MethodPut {
+			SetTracePoint(arg)
+		} else {
+			ResetTracePoint(arg)
+		}
+
+	case r.Method == http.MethodPut && resource == "output" && arg == "dir" && h.output != nil:
+		var body []byte
+		if body, err = ioutil.ReadAll(r.Body); err == nil {
+			h.output.SetDir(strings.TrimSpace(string(body)))
+		}
+
+	case r.Method == http.MethodPut && resource == "output" && arg == "stderr" && h.output != nil:
+		var body []byte
+		if body, err = ioutil.ReadAll(r.Body); err == nil {
+			var toStderr bool
+			if toStderr, err = strconv.ParseBool(strings.TrimSpace(string(body))); err == nil {
+				h.output.SetToStderr(toStderr)
+			}
+		}
+
+	case r.Method == http.MethodPost && resource == "recorder" && arg == "" && h.recorder != nil:
+		dumped = h.recorder.DumpFlightRecorder()
+
+	default:
+		http.Error(w, fmt.Sprintf("%s %s not found", r.Method, r.URL.Path), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	state := AdminState{
+		Mode:        GetGlobalLogMode(),
+		Files:       getFileLogModes(),
+		TracePoints: getTracePoints(),
+	}
+	if h.output != nil {
+		state.Output = &AdminOutputState{
+			Dir:      h.output.Dir(),
+			ToStderr: h.output.ToStderr(),
+		}
+	}
+	if h.recorder != nil && h.recorder.recorder != nil {
+		state.Recorder = &AdminRecorderState{
+			Pending: len(h.recorder.recorder.pending(false)),
+			Dumped:  dumped,
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(state)
+}
+
+// readMode reads the mode specified in the request body.
+func readMode(r *http.Request) (Mode, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return DisabledMode, err
+	}
+	return ParseMode(strings.TrimSpace(string(body)))
+}
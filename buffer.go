@@ -0,0 +1,134 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxPooledBufferSize is the capacity beyond which buffers aren't returned
+// to the pool, so that the occasional large entry doesn't pin memory.
+const maxPooledBufferSize = 64 << 10 // 64 KiB
+
+// bufferPool holds the buffers log entries are encoded into before being
+// written out. Pointers to slices are pooled (as opposed to slices) so that
+// returning them doesn't allocate.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+// putBuffer returns the provided buffer to the pool, unless it has grown too
+// large. It's not to be used thereafter.
+func putBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBufferSize {
+		return
+	}
+	*b = (*b)[:0]
+	bufferPool.Put(b)
+}
+
+// datePrefix is the date and time, to the second, as formatted in log
+// headers: yymmdd hh:mm:ss.
+type datePrefix struct {
+	sec int64
+	loc *time.Location
+	b   [len("060102 15:04:05")]byte
+}
+
+// datePrefixes caches the most recently formatted date prefix, for local
+// and UTC time stamps each (see LUTC). Log entries are typically written out
+// many to a second, so they're formatted once a second at most.
+var datePrefixes [2]atomic.Value // type: *datePrefix
+
+// getDatePrefix returns the date prefix for the provided time stamp, using
+// the cached one if it's for the same second.
+func getDatePrefix(t time.Time) *datePrefix {
+	slot := &datePrefixes[0]
+	if t.Location() == time.UTC {
+		slot = &datePrefixes[1]
+	}
+	sec := t.Unix()
+	if p, ok := slot.Load().(*datePrefix); ok && p.sec == sec && p.loc == t.Location() {
+		return p
+	}
+
+	p := &datePrefix{sec: sec, loc: t.Location()}
+	year, month, day := t.Date()
+	if year < 2000 {
+		year = 2000
+	}
+	hour, min, s := t.Clock()
+	b := p.b[:0]
+	b = appendInt(b, year-2000, 2)
+	b = appendInt(b, int(month), 2)
+	b = appendInt(b, day, 2)
+	b = append(b, ' ')
+	b = appendInt(b, hour, 2)
+	b = append(b, ':')
+	b = appendInt(b, min, 2)
+	b = append(b, ':')
+	appendInt(b, s, 2)
+	slot.Store(p)
+	return p
+}
+
+// date returns the date component of the prefix, yymmdd.
+func (p *datePrefix) date() []byte {
+	return p.b[:len("060102")]
+}
+
+// clock returns the time component of the prefix, hh:mm:ss.
+func (p *datePrefix) clock() []byte {
+	return p.b[len("060102 "):]
+}
+
+const zeros = "00000000000000000000"
+
+// appendInt appends the decimal representation of the provided integer,
+// zero-padded to the provided width. Give a negative width to avoid
+// zero-padding.
+func appendInt(b []byte, i int, wid int) []byte {
+	if wid < 0 || i < 0 {
+		return strconv.AppendInt(b, int64(i), 10)
+	}
+
+	digits := 1
+	for q := i; q >= 10; q /= 10 {
+		digits++
+	}
+	if digits < wid {
+		digits = wid
+	}
+
+	// Assemble the decimal in place, in reverse order.
+	n := len(b)
+	b = append(b, zeros[:digits]...)
+	for j := len(b) - 1; j >= n && i > 0; j-- {
+		b[j] = byte('0' + i%10)
+		i /= 10
+	}
+	return b
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type filePatterns []string
+
+func (l *filePatterns) String() string {
+	return fmt.Sprint(*l)
+}
+
+func (l *filePatterns) Set(value string) error {
+	for _, pattern := range strings.Split(value, ",") {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return errors.New(
+				fmt.Sprintf("Improperly formatted pattern: %s, %s", pattern, err))
+		}
+		*l = append(*l, pattern)
+	}
+	return nil
+}
+
+// matches checks whether the provided file name matches any of the patterns,
+// either in its entirety or just by its base name.
+func (l filePatterns) matches(fname string) bool {
+	for _, pattern := range l {
+		if matched, _ := filepath.Match(pattern, fname); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(fname)); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/irfansharif/log"
+)
+
+// $ logcat -help
+// Usage of logcat:
+//   -file value
+//         Comma-separated list of filename patterns, only entries logged from matching files are shown.
+//   -follow
+//         Wait for additional entries to be written out, following log rotations.
+//   -mode value
+//         Log modes to show entries for (defaults to all).
+//   -redact
+//         Redact values marked as potentially sensitive (see log.RedactableOutput).
+//   -regex string
+//         Only show entries with messages matching the regular expression.
+//
+// $ logcat -mode 'warn|error' \
+//          -file 'store.go,kv/*.go' \
+//          -regex 'range [0-9]+' \
+//          -follow \
+//          /path/to/dir /path/to/logger.host.user.2018-04-10.22:43:54.717.7989.log
+//
+// Directories are expected to have been written to by log.LogRotationWriter;
+// entries across all the log files within are merged by timestamp. When
+// following, the <program>.log symlinks within are watched for log rotations.
+
+const pollInterval = 250 * time.Millisecond
+
+func main() {
+	var modeFlag log.ModeFlag
+	var fileFlag filePatterns
+	var regexFlag string
+	var followFlag bool
+	var redactFlag bool
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s: [flags] <file|dir>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Var(&modeFlag, "mode",
+		"Log modes to show entries for (defaults to all).")
+	flag.Var(&fileFlag, "file",
+		"Comma-separated list of filename patterns, only entries logged from matching files are shown.")
+	flag.StringVar(&regexFlag, "regex", "",
+		"Only show entries with messages matching the regular expression.")
+	flag.BoolVar(&followFlag, "follow", false,
+		"Wait for additional entries to be written out, following log rotations.")
+	flag.BoolVar(&redactFlag, "redact", false,
+		"Redact values marked as potentially sensitive (see log.RedactableOutput).")
+
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f := &filter{mode: log.InfoMode | log.WarnMode | log.ErrorMode | log.FatalMode | log.DebugMode}
+	if modeFlag.IsSet() {
+		f.mode = modeFlag.Mode
+	}
+	f.patterns = fileFlag
+	if regexFlag != "" {
+		regex, err := regexp.Compile(regexFlag)
+		if err != nil {
+			fatal(err)
+		}
+		f.regex = regex
+	}
+
+	c := &logcat{filter: f, redact: redactFlag, following: followFlag, out: bufio.NewWriter(os.Stdout)}
+	for _, arg := range flag.Args() {
+		if err := c.add(arg); err != nil {
+			fatal(err)
+		}
+	}
+	if err := c.merge(); err != nil {
+		fatal(err)
+	}
+	if !followFlag {
+		return
+	}
+	for {
+		if err := c.follow(); err != nil {
+			fatal(err)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", filepath.Base(os.Args[0]), err)
+	os.Exit(1)
+}
+
+type filter struct {
+	mode     log.Mode
+	patterns filePatterns
+	regex    *regexp.Regexp
+}
+
+func (f *filter) matches(e *log.Entry) bool {
+	// Entries without a header (see emit) are only filtered by file and regex.
+	if e.Mode != log.DisabledMode && (e.Mode&f.mode) == log.DisabledMode {
+		return false
+	}
+	if len(f.patterns) != 0 && !f.patterns.matches(e.File) {
+		return false
+	}
+	if f.regex != nil && !f.regex.MatchString(e.Message) {
+		return false
+	}
+	return true
+}
+
+// source is a single log file being read from.
+type source struct {
+	dir  string // Directory the file was found in, if any
+	name string // Base name of the file
+	f    *os.File
+	dec  *log.EntryDecoder
+	next *log.Entry // Next entry to be emitted, nil if the file is exhausted
+}
+
+func open(dir, path string, follow bool) (*source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &source{dir: dir, name: filepath.Base(path), f: f}
+	if follow {
+		// Files may be read from as they're being written to.
+		s.dec = log.NewEntryDecoder(f, log.FollowStream())
+	} else {
+		s.dec = log.NewEntryDecoder(f)
+	}
+	return s, nil
+}
+
+func (s *source) advance() error {
+	return s.decode(s.dec.Decode)
+}
+
+// flush is like advance, for once the file is known to no longer be written
+// to (see log.EntryDecoder.Flush).
+func (s *source) flush() error {
+	return s.decode(s.dec.Flush)
+}
+
+func (s *source) decode(decode func(*log.Entry) error) error {
+	var e log.Entry
+	if err := decode(&e); err != nil {
+		s.next = nil
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	s.next = &e
+	return nil
+}
+
+type logcat struct {
+	filter    *filter
+	redact    bool
+	following bool
+	out       *bufio.Writer
+	sources   []*source
+	dirs      []string
+}
+
+// add adds the provided file, or every log file within the provided
+// directory, to the set of sources read from.
+func (c *logcat) add(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		s, err := open("", path, c.following)
+		if err != nil {
+			return err
+		}
+		c.sources = append(c.sources, s)
+		return nil
+	}
+
+	files, err := log.ListLogFiles(path)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		s, err := open(path, filepath.Join(path, fi.Name), c.following)
+		if err != nil {
+			return err
+		}
+		c.sources = append(c.sources, s)
+	}
+	c.dirs = append(c.dirs, path)
+	return nil
+}
+
+// merge writes out all the entries across all sources, ordered by timestamp.
+// Entries within a single source are assumed to already be ordered.
+func (c *logcat) merge() error {
+	var h sourceHeap
+	for _, s := range c.sources {
+		if err := s.advance(); err != nil {
+			return err
+		}
+		if s.next != nil {
+			h = append(h, s)
+		}
+	}
+	heap.Init(&h)
+
+	for len(h) > 0 {
+		s := h[0]
+		if err := c.emit(s.next); err != nil {
+			return err
+		}
+		if err := s.advance(); err != nil {
+			return err
+		}
+		if s.next == nil {
+			heap.Pop(&h)
+		} else {
+			heap.Fix(&h, 0)
+		}
+	}
+	return c.out.Flush()
+}
+
+// follow picks up log rotations by looking for <program>.log symlinks
+// pointing to files not yet being read from, writes out all the entries
+// written since the last invocation (ordered by timestamp), and stops reading
+// from files that are no longer pointed to by a symlink.
+func (c *logcat) follow() error {
+	current := make(map[string]bool) // Set of files pointed to by symlinks.
+	for _, dir := range c.dirs {
+		links, err := filepath.Glob(filepath.Join(dir, "*.log"))
+		if err != nil {
+			return err
+		}
+		for _, link := range links {
+			if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+				continue
+			}
+			target, err := os.Readlink(link)
+			if err != nil {
+				continue // Rotation in progress, we'll pick it up the next time around.
+			}
+			path := filepath.Join(dir, filepath.Base(target))
+			current[path] = true
+			if !c.reading(path) {
+				s, err := open(dir, path, true)
+				if err != nil {
+					continue // Rotation in progress, we'll pick it up the next time around.
+				}
+				c.sources = append(c.sources, s)
+			}
+		}
+	}
+
+	var batch []*log.Entry
+	sources := c.sources[:0]
+	for _, s := range c.sources {
+		for {
+			if err := s.advance(); err != nil {
+				return err
+			}
+			if s.next == nil {
+				break
+			}
+			batch = append(batch, s.next)
+		}
+
+		if s.dir != "" && !current[filepath.Join(s.dir, s.name)] {
+			// The file has been rotated away from, and we've read everything
+			// written to it, barring the entries still retained.
+			for {
+				if err := s.flush(); err != nil {
+					return err
+				}
+				if s.next == nil {
+					break
+				}
+				batch = append(batch, s.next)
+			}
+			s.f.Close()
+			continue
+		}
+		sources = append(sources, s)
+	}
+	c.sources = sources
+
+	sort.SliceStable(batch, func(i, j int) bool {
+		return batch[i].Time.Before(batch[j].Time)
+	})
+	for _, e := range batch {
+		if err := c.emit(e); err != nil {
+			return err
+		}
+	}
+	return c.out.Flush()
+}
+
+func (c *logcat) reading(path string) bool {
+	for _, s := range c.sources {
+		if s.dir != "" && filepath.Join(s.dir, s.name) == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *logcat) emit(e *log.Entry) error {
+	if !c.filter.matches(e) {
+		return nil
+	}
+	if c.redact {
+		e.Message = log.Redact(e.Message)
+	}
+	if e.Mode == log.DisabledMode && e.Stack == "" {
+		// Lines preceding the first log header in a file (as opposed to
+		// being part of an entry), write them out as is.
+		_, err := fmt.Fprintln(c.out, e.Message)
+		return err
+	}
+	return e.Format(c.out)
+}
+
+// sourceHeap is a min-heap of sources, ordered by the timestamp of their next
+// entry.
+type sourceHeap []*source
+
+func (h sourceHeap) Len() int            { return len(h) }
+func (h sourceHeap) Less(i, j int) bool  { return h[i].next.Time.Before(h[j].next.Time) }
+func (h sourceHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *sourceHeap) Push(x interface{}) { *h = append(*h, x.(*source)) }
+func (h *sourceHeap) Pop() interface{} {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// $ logger-debugger -help
+// Usage of logger-debugger:
+//   -addr host:port
+//         Server address in form of host:port for running instance of logger that is to be configured.
+//   -log-backtrace-at value
+//         Comma-separated list of filename:N settings, when any logging statement at
+//         the specified locations are executed, a stack trace will be emitted.
+//   -log-dir string
+//         Configure server to write log files in this directory.
+//   -log-filter value
+//         Comma-separated list of pattern:level settings for file-filtered logging to configure server to apply.
+//   -log-level value
+//         Configure server to log at specified level (info|debug|warn|error|fatal).
+//   -log-to-stderr
+//         Configure server to log to standard error.
+//   -prefix string
+//         Path the server's log.AdminHandler is mounted at. (default "/debug/log")
+//
+// $ logger-debugger -addr <host>:<port> \
+//                   -log-level debug \
+//                   -log-dir /path/to/another/dir \
+//                   -log-filter x.go:debug \
+//                   -log-backtrace-at y.go:42
+//
+// The changes are applied through the server's log.AdminHandler, after which
+// the server's resulting logging configuration is printed out. Without any
+// -log-* flags, the configuration is printed out as is.
+
+func main() {
+	var addrFlag, prefixFlag string
+	var logDirFlag, logLevelFlag, logFilterFlag, backtracePointFlag string
+	var logToStderrFlag bool
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.StringVar(&addrFlag, "addr", "",
+		"Server address in form of host:port for running instance of logger that is to be configured.")
+	flag.StringVar(&prefixFlag, "prefix", "/debug/log",
+		"Path the server's log.AdminHandler is mounted at.")
+	flag.StringVar(&logDirFlag, "log-dir", "",
+		"Configure server to write log files in this directory.")
+	flag.BoolVar(&logToStderrFlag, "log-to-stderr", false,
+		"Configure server to log to standard error.")
+	flag.StringVar(&logLevelFlag, "log-level", "",
+		"Configure server to log at specified level (info|debug|warn|error|fatal).")
+	flag.StringVar(&logFilterFlag, "log-filter", "",
+		"Comma-separated list of pattern:level settings for file-filtered logging to configure server to apply.")
+	flag.StringVar(&backtracePointFlag, "log-backtrace-at", "",
+		"Comma-separated list of filename:N settings, when any logging statement at "+
+			"the specified locations are executed, a stack trace will be emitted.")
+
+	flag.Parse()
+
+	if addrFlag == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := &client{base: fmt.Sprintf("http://%s/%s", addrFlag, strings.Trim(prefixFlag, "/"))}
+
+	// We only apply the settings explicitly specified.
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if set["log-level"] {
+		c.put("/mode", logLevelFlag)
+	}
+	if set["log-filter"] {
+		for _, f := range strings.Split(logFilterFlag, ",") {
+			i := strings.LastIndex(f, ":")
+			if i < 0 {
+				fatal(fmt.Errorf("Improperly formatted filter: %s, expected fname.go:mode", f))
+			}
+			c.put("/files/"+f[:i], f[i+1:])
+		}
+	}
+	if set["log-backtrace-at"] {
+		for _, tp := range strings.Split(backtracePointFlag, ",") {
+			c.put("/tracepoints/"+tp, "")
+		}
+	}
+	if set["log-dir"] {
+		c.put("/output/dir", logDirFlag)
+	}
+	if set["log-to-stderr"] {
+		c.put("/output/stderr", strconv.FormatBool(logToStderrFlag))
+	}
+
+	state := c.do(http.MethodGet, "/", "")
+	os.Stdout.Write(state)
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", filepath.Base(os.Args[0]), err)
+	os.Exit(1)
+}
+
+// client issues requests against a log.AdminHandler, exiting the process if
+// any of them fail.
+type client struct {
+	base string // URL the admin handler is mounted at
+}
+
+func (c *client) put(path, body string) {
+	c.do(http.MethodPut, path, body)
+}
+
+func (c *client) do(method, path, body string) []byte {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.base+path, r)
+	if err != nil {
+		fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fatal(err)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		fatal(fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(b)))
+	}
+	return b
+}
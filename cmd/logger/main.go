@@ -3,8 +3,9 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/irfansharif/log"
 	"github.com/irfansharif/log/cmd/logger/pkg"
@@ -24,61 +25,52 @@ import (
 //   -log-backtrace-at value
 //         Comma-separated list of filename:N settings, when any logging statement at
 //         the specified locations are executed, a stack trace will be emitted.
+//   -log-format value
+//         Format log entries are written out in (text|json).
+//   -admin-addr host:port
+//         Serve log.AdminHandler at host:port/debug/log, logging periodically until killed.
 
 func main() {
-	var logDirFlag string
-	var logToStderrFlag bool
-	var logModeFlag logMode
-	var logFilterFlag logFilter
-	var backtracePointFlag backtracePoints
+	var adminAddrFlag string
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		flag.PrintDefaults()
 	}
-	flag.StringVar(&logDirFlag, "log-dir", "",
-		"Write log files in this directory.")
-	flag.BoolVar(&logToStderrFlag, "log-to-stderr", false,
-		"Log to standard error.")
-	flag.Var(&logModeFlag, "log-mode",
-		"Log mode for logs emitted globally (can be overrode using -log-filter)")
-	flag.Var(&logFilterFlag, "log-filter",
-		"Comma-separated list of pattern:level settings for file-filtered logging modes.")
-	flag.Var(&backtracePointFlag, "log-backtrace-at",
-		"Comma-separated list of filename:N settings, when any logging statement at "+
-			"the specified locations are executed, a stack trace will be emitted.")
+	logf := log.Ldate | log.Ltime | log.Lmicroseconds | log.Llongfile | log.LUTC | log.Lmode
+	logger := log.RegisterFlags(flag.CommandLine, log.Flags(logf), log.SkipBasePath())
+	flag.StringVar(&adminAddrFlag, "admin-addr", "",
+		"Serve log.AdminHandler at host:port/debug/log, logging periodically until killed.")
 
 	flag.Parse()
 
-	if logModeFlag.set {
-		log.SetGlobalLogMode(log.Mode(logModeFlag.m))
-	}
-	for _, flm := range logFilterFlag {
-		log.SetFileLogMode(flm.fname, flm.fmode)
-	}
-	for _, tp := range backtracePointFlag {
-		log.SetTracePoint(tp)
-	}
-
-	writer := ioutil.Discard
-	if logDirFlag != "" {
-		writer = log.LogRotationWriter(logDirFlag, 50<<20 /* 50 MiB */)
-	}
-	if logToStderrFlag {
-		writer = log.MultiWriter(writer, os.Stderr)
-	}
-	writer = log.SynchronizedWriter(writer)
-
-	logf := log.Ldate | log.Ltime | log.Lmicroseconds | log.Llongfile | log.LUTC | log.Lmode
-	logger := log.New(log.Writer(writer), log.Flags(logf), log.SkipBasePath())
-
-	logger.Debug("log-dir:", logDirFlag)
-	logger.Debug("log-to-stderr:", logToStderrFlag)
-	logger.Debug("log-mode:", logModeFlag.String())
-	logger.Debug("log-filter:", logFilterFlag.String())
-	logger.Debug("log-backtrace-at:", backtracePointFlag.String())
+	flag.VisitAll(func(f *flag.Flag) {
+		logger.Debug(f.Name+":", f.Value)
+	})
 
 	logger.Info("from main!")
 	pkg.Log(logger)
 	subpkg.Log(logger)
+
+	if adminAddrFlag == "" {
+		return
+	}
+
+	// Reconfigurable using logger-debugger -addr <admin-addr>.
+	output := logger.Writer().(*log.Output)
+	mux := http.NewServeMux()
+	mux.Handle("/debug/log/", http.StripPrefix("/debug/log", log.AdminHandler(log.AdminOutput(output))))
+	go func() {
+		if err := http.ListenAndServe(adminAddrFlag, mux); err != nil {
+			logger.Fatal(err)
+			os.Exit(1)
+		}
+	}()
+
+	for range time.Tick(time.Second) {
+		logger.Debug("from main!")
+		logger.Info("from main!")
+		pkg.Log(logger)
+		subpkg.Log(logger)
+	}
 }
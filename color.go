@@ -0,0 +1,169 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// ANSI escape sequences used to colorize text log headers.
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorYellow = "\x1b[33m"
+	colorDim    = "\x1b[2m"
+)
+
+// colorMode determines whether a Logger colorizes its text log headers.
+type colorMode int
+
+const (
+	colorAuto colorMode = iota // Colorize if writing out to a terminal, see Colorize
+	colorAlways
+	colorNever
+)
+
+// Colorize configures a Logger instance to always (or never) colorize the
+// mode and file name components of text log headers, red for errors and
+// fatal errors, yellow for warnings, and dimmed for debug logs:
+//
+//	E180419 06:33:04.606396 fname.go:42] message
+//	^ red                   ^ red
+//
+// By default, they're colorized only when writing out to a terminal
+// (including through an Output writing out to standard error alone, and not
+// to log files), unless the NO_COLOR environment variable is set. This is
+// determined once, when the Logger is created, barring Outputs whose
+// destination can be changed thereafter (see Output.SetDir).
+func Colorize(enabled bool) option {
+	return func(l *Logger) {
+		l.color = colorNever
+		if enabled {
+			l.color = colorAlways
+		}
+	}
+}
+
+// Logger.resolveColor resolves whether the Logger's text log headers are to
+// be colorized, if left to be determined by its writer, so that it isn't for
+// every entry. Outputs writing out to standard error are the exception, their
+// destination is checked for every entry instead (cheaply, see
+// Output.updateTerminal).
+func (l *Logger) resolveColor() {
+	l.colorOutput = nil
+	if l.color != colorAuto {
+		return
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		l.color = colorNever
+		return
+	}
+	if o := outputOf(l.w); o != nil {
+		l.colorOutput = o
+		return
+	}
+	l.color = colorNever
+	if isTerminal(l.w) {
+		l.color = colorAlways
+	}
+}
+
+// Logger.colorized returns whether the Logger's text log headers are to be
+// colorized.
+func (l *Logger) colorized() bool {
+	switch l.color {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	default:
+		return l.colorOutput != nil && isTerminal(l.colorOutput)
+	}
+}
+
+// modeColor returns the color the provided mode is to be colorized with, if
+// any.
+func modeColor(m Mode) string {
+	switch m {
+	case ErrorMode, FatalMode:
+		return colorRed
+	case WarnMode:
+		return colorYellow
+	case DebugMode:
+		return colorDim
+	default:
+		return ""
+	}
+}
+
+var stderrTerminal struct {
+	once       sync.Once
+	isTerminal bool
+}
+
+// outputOf returns the Output the provided writer writes out to exclusively,
+// if any, seeing through synchronizedWriters.
+func outputOf(w io.Writer) *Output {
+	switch w := w.(type) {
+	case *synchronizedWriter:
+		return outputOf(w.w)
+	case *Output:
+		return w
+	default:
+		return nil
+	}
+}
+
+// Output.updateTerminal records whether the Output writes out to a terminal
+// exclusively, for isTerminal. It's to be called with the Output's mutex
+// held, whenever its destination changes.
+func (o *Output) updateTerminal() {
+	var terminal int32
+	if o.toStderr && o.rotation == nil && isTerminal(os.Stderr) {
+		terminal = 1
+	}
+	atomic.StoreInt32(&o.terminal, terminal)
+}
+
+// isTerminal returns whether the provided writer writes out to a terminal
+// exclusively, seeing through this package's own writers.
+func isTerminal(w io.Writer) bool {
+	switch w := w.(type) {
+	case *synchronizedWriter:
+		return isTerminal(w.w)
+	case *Output:
+		return atomic.LoadInt32(&w.terminal) != 0
+	case *os.File:
+		if w != os.Stderr {
+			return isCharDevice(w)
+		}
+		stderrTerminal.once.Do(func() {
+			stderrTerminal.isTerminal = isCharDevice(os.Stderr)
+		})
+		return stderrTerminal.isTerminal
+	default:
+		return false
+	}
+}
+
+// isCharDevice returns whether the provided file is a character device, as
+// terminals are.
+func isCharDevice(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,221 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Config is a declarative description of the logging configuration, as read
+// from a JSON file. For e.g.:
+//
+//	{
+//	  "mode": "info|warn|error",
+//	  "files": {
+//	    "store.go": "debug"
+//	  },
+//	  "tracepoints": ["raft.go:42"],
+//	  "format": "json",
+//	  "output": {
+//	    "dir": "/path/to/dir",
+//	    "stderr": false,
+//	    "max_size": 52428800
+//	  }
+//	}
+//
+// All fields are optional, anything left unspecified is left as is.
+type Config struct {
+	Mode        *Mode           `json:"mode,omitempty"`        // See SetGlobalLogMode
+	Files       map[string]Mode `json:"files,omitempty"`       // See SetFileLogMode
+	TracePoints []string        `json:"tracepoints,omitempty"` // See SetTracePoint
+	Format      *Format         `json:"format,omitempty"`      // See OutputFormat
+	Output      *OutputConfig   `json:"output,omitempty"`      // See Output
+}
+
+// OutputConfig describes where log entries are written out to, see Output.
+type OutputConfig struct {
+	Dir      *string `json:"dir,omitempty"`      // See Output.SetDir
+	ToStderr *bool   `json:"stderr,omitempty"`   // See Output.SetToStderr
+	MaxSize  int     `json:"max_size,omitempty"` // See Output.SetMaxSize
+}
+
+// LoadConfig reads and parses the JSON config file at the specified path.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(b)
+}
+
+// parseConfig parses the provided JSON config.
+func parseConfig(b []byte) (*Config, error) {
+	c := &Config{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Apply applies the config globally, and to the provided Logger (if
+// non-nil). The format applies to the Logger; the output to its writer, if
+// it's an *Output (as is the case for Loggers returned by RegisterFlags, for
+// e.g.). Unspecified fields are left as is.
+func (c *Config) Apply(l *Logger) {
+	c.applyDiff(nil, l)
+}
+
+// applyDiff applies only what's changed in the config since the previous
+// one, if any. File log modes and tracepoints no longer specified are reset;
+// the global log mode, format and output, if no longer specified, are left
+// as is.
+func (c *Config) applyDiff(prev *Config, l *Logger) {
+	if prev == nil {
+		prev = &Config{}
+	}
+
+	if c.Mode != nil && (prev.Mode == nil || *prev.Mode != *c.Mode) {
+		SetGlobalLogMode(*c.Mode)
+	}
+
+	for fname := range prev.Files {
+		if _, ok := c.Files[fname]; !ok {
+			ResetFileLogMode(fname)
+		}
+	}
+	for fname, m := range c.Files {
+		if pm, ok := prev.Files[fname]; !ok || pm != m {
+			SetFileLogMode(fname, m)
+		}
+	}
+
+	tps := make(map[string]bool)
+	for _, tp := range c.TracePoints {
+		tps[tp] = true
+	}
+	ptps := make(map[string]bool)
+	for _, tp := range prev.TracePoints {
+		ptps[tp] = true
+		if !tps[tp] {
+			ResetTracePoint(tp)
+		}
+	}
+	for tp := range tps {
+		if !ptps[tp] {
+			SetTracePoint(tp)
+		}
+	}
+
+	if l == nil {
+		return
+	}
+
+	if c.Format != nil && (prev.Format == nil || *prev.Format != *c.Format) {
+		l.setFormat(*c.Format)
+	}
+
+	o, ok := l.Writer().(*Output)
+	if c.Output == nil || !ok {
+		return
+	}
+	po := prev.Output
+	if po == nil {
+		po = &OutputConfig{}
+	}
+	if c.Output.MaxSize > 0 && c.Output.MaxSize != po.MaxSize {
+		o.SetMaxSize(c.Output.MaxSize)
+	}
+	if c.Output.Dir != nil && (po.Dir == nil || *po.Dir != *c.Output.Dir) {
+		o.SetDir(*c.Output.Dir)
+	}
+	if c.Output.ToStderr != nil && (po.ToStderr == nil || *po.ToStderr != *c.Output.ToStderr) {
+		o.SetToStderr(*c.Output.ToStderr)
+	}
+}
+
+// configPollInterval is how often WatchConfig checks for changes.
+var configPollInterval = time.Second
+
+// WatchConfig loads and applies the JSON config file at the specified path
+// (see Config), and then polls the file for changes, applying only what's
+// changed (through SetGlobalLogMode, SetFileLogMode, SetTracePoint, etc.)
+// whenever it is. This way changes made by other means (say, through
+// AdminHandler) aren't undone unless the file changes the same settings. File
+// log modes and tracepoints removed from the file are reset.
+//
+// The config is applied to the provided Logger as it is in Config.Apply; it
+// may be nil. Errors loading the initial config are returned, and those
+// reloading it are logged to the Logger (if non-nil), retaining the previous
+// config. The returned function stops watching the file.
+func WatchConfig(path string, l *Logger) (stop func(), err error) {
+	// The file is stat-ed before it's read, so that changes made in between
+	// are picked up the next time around.
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := parseConfig(b)
+	if err != nil {
+		return nil, err
+	}
+	c.Apply(l)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			ninfo, err := os.Stat(path)
+			if err != nil || (ninfo.ModTime().Equal(info.ModTime()) && ninfo.Size() == info.Size()) {
+				continue // Transient errors (think file being replaced) are retried.
+			}
+			nb, err := ioutil.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			info = ninfo
+			if bytes.Equal(nb, b) {
+				continue
+			}
+			b = nb
+
+			nc, err := parseConfig(nb)
+			if err != nil {
+				if l != nil {
+					l.Errorf("unable to reload log config %s: %s", path, err)
+				}
+				continue
+			}
+			nc.applyDiff(c, l)
+			c = nc
+		}
+	}()
+	return func() { close(done) }, nil
+}
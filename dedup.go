@@ -0,0 +1,98 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Dedup configures a Logger instance to collapse consecutive, identical
+// entries (same mode, message and call site) into the first one, followed by
+// one noting the number of times it was repeated:
+//
+//	W180419 06:33:04.606396 fname.go:42] connection refused
+//	W180419 06:33:05.606396 fname.go:42] last message repeated 41 times
+//
+// The count is written out once a different entry is logged, or after the
+// provided window has elapsed since the first repetition, whichever comes
+// first. In the latter case, subsequent repetitions are counted anew.
+func Dedup(window time.Duration) option {
+	return func(l *Logger) {
+		l.dedup = &dedupState{window: window}
+	}
+}
+
+// dedupState tracks the last entry emitted by a Logger configured using
+// Dedup, and the number of times it's been repeated since.
+type dedupState struct {
+	sync.Mutex
+	window time.Duration
+
+	l        *Logger // Logger the last entry was emitted through
+	mode     Mode
+	file     string
+	line     int
+	data     string
+	repeated int
+	timer    *time.Timer // Flushes the count after the window elapses, if running
+}
+
+// emit emits the provided entry through the provided Logger unless it's a
+// repetition of the last one, having first flushed the repetition count for
+// the last entry, if any.
+func (d *dedupState) emit(l *Logger, lmode Mode, t time.Time, file string, line int, data string, stack []byte) {
+	l.helper()
+
+	d.Lock()
+	defer d.Unlock()
+
+	if lmode == d.mode && line == d.line && file == d.file && data == d.data {
+		d.repeated++
+		l.recordDrop(lmode)
+		if stack != nil {
+			// The backtrace is emitted regardless.
+			l.emitStack(t, file, line, stack)
+		}
+		if d.timer == nil {
+			d.timer = time.AfterFunc(d.window, d.flush)
+		}
+		return
+	}
+
+	d.flushLocked(t)
+	d.l, d.mode, d.file, d.line, d.data = l, lmode, file, line, data
+	l.emit(lmode, t, file, line, data, stack)
+}
+
+func (d *dedupState) flush() {
+	d.Lock()
+	defer d.Unlock()
+	d.flushLocked(time.Now())
+}
+
+func (d *dedupState) flushLocked(t time.Time) {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.repeated == 0 {
+		return
+	}
+	d.l.emit(d.mode, t, d.file, d.line,
+		fmt.Sprintf("last message repeated %d times", d.repeated), nil)
+	d.repeated = 0
+}
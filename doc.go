@@ -21,23 +21,26 @@
 //             Write log files in this directory.
 //       -log-to-stderr
 //             Log to standard error.
-//       -log-level (info|debug|warn|error)
-//             Log level for logs emitted (global, can be overrode using -log-filter).
+//       -log-mode value
+//             Log mode for logs emitted globally (can be overrode using -log-filter).
 //       -log-filter value
-//             Comma-separated list of pattern:level settings for file-filtered logging.
+//             Comma-separated list of pattern:mode settings for file-filtered logging modes.
 //       -log-backtrace-at value
 //             Comma-separated list of filename:N settings, when any logging statement at
 //             the specified locations are executed, a stack trace will be emitted.
+//       -log-format value
+//             Format log entries are written out in (text|json).
 //
-//     $ <binary-name> -log-level info \
+//     $ <binary-name> -log-mode 'info|warn|error' \
 //                     -log-dir /path/to/dir \
 //                     -log-to-stderr \
 //                     -log-filter f.go:warn,g/h/*.go:debug \
 //                     -log-backtrace-at y.go:42
 //
-// These hooks can be invoked at runtime, what this means is that if needed, a
-// running service could opt-in to provide open endpoints to accept logger
-// reconfigurations (via RPCs or otherwise).
+// The flags above can be registered using RegisterFlags. These hooks can be
+// invoked at runtime, what this means is that if needed, a running service
+// could opt-in to provide open endpoints to accept logger reconfigurations
+// (via RPCs or otherwise). AdminHandler provides one such endpoint over HTTP.
 //
 // Basic example:
 //
@@ -60,4 +63,9 @@
 //      logf := log.Lmode | log.Ldate | log.Ltime | log.Llongfile
 //
 //      logger.New(log.Writer(writer), log.Flags(logf))
+//
+// Each log entry is written out in a single write, terminated by a newline;
+// one is appended to messages that don't already end with one, as those
+// logged using Logger.{Info,Warn,Error,Fatal,Debug}f typically don't. Log
+// files can therefore be read back entry by entry, see EntryDecoder.
 package log
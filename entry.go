@@ -0,0 +1,247 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a single, structured log entry. Multi-line messages (stack traces
+// included) are captured in their entirety within Message, sans the trailing
+// newline.
+type Entry struct {
+	Mode    Mode      `json:"mode"`    // Mode of the logging statement
+	Time    time.Time `json:"time"`    // Time the entry was logged
+	File    string    `json:"file"`    // File name of the logging statement, as it appears in the header
+	Line    int       `json:"line"`    // Line number of the logging statement
+	Message string    `json:"message"` // Logged message
+
+	// Stack is the backtrace emitted for the logging statement, if its
+	// tracepoint is enabled (see SetTracePoint). It's only set for entries
+	// written out using JSONFormat; ones filtered out are written out
+	// nonetheless, with only the time stamp, file name, line number and
+	// backtrace set.
+	Stack string `json:"stack,omitempty"`
+}
+
+// Format writes out the entry in the header format produced by LstdFlags,
+// albeit with the file name written out as is (as opposed to shortened). The
+// backtrace, if any, is written out preceding it (as it is with the text
+// format), and on its own for entries that were filtered out.
+func (e Entry) Format(w io.Writer) error {
+	l := &Logger{flag: Lmode | Ldate | Ltime | Lmicroseconds | LUTC | Llongfile}
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	b := append(*buf, e.Stack...)
+	if e.Mode != DisabledMode {
+		b = l.appendHeader(b, e.Mode, e.Time, e.File, e.Line, "")
+		b = append(b, e.Message...)
+		b = append(b, '\n')
+	}
+	*buf = b
+	_, err := w.Write(b)
+	return err
+}
+
+// decodeEntry decodes the log entry written out by a Logger in a single write
+// (see Logger.emit), for writers forwarding entries elsewhere (think
+// SyslogWriter). The backtrace preceding the entry in the same write, if any,
+// is attached to it as its Stack. Backtraces written out on their own are
+// decoded as headerless entries.
+func decodeEntry(b []byte) (Entry, error) {
+	d := NewEntryDecoder(bytes.NewReader(b))
+	var e Entry
+	if err := d.Decode(&e); err != nil && err != io.EOF {
+		return Entry{}, err
+	}
+	if e.Mode != DisabledMode || e.Stack != "" {
+		return e, nil
+	}
+
+	var next Entry
+	if err := d.Decode(&next); err != nil {
+		return e, nil
+	}
+	next.Stack = e.Message
+	return next, nil
+}
+
+// messageWithStack returns the entry's message, followed by its backtrace if
+// any.
+func (e Entry) messageWithStack() string {
+	stack := strings.TrimRight(e.Stack, "\n")
+	if stack == "" {
+		return e.Message
+	}
+	if e.Message == "" {
+		return stack
+	}
+	return e.Message + "\n" + stack
+}
+
+// headerRegex matches log headers that include, at the very least, the mode,
+// date, time and file name components (see Flags). The microseconds
+// component is optional.
+var headerRegex = regexp.MustCompile(
+	`^([IWEFD])(\d{2})(\d{2})(\d{2}) (\d{2}):(\d{2}):(\d{2})(?:\.(\d{6}))? (\S+):(\d+)\] ?`)
+
+// EntryDecoder reads log entries off of an input stream, as written out by a
+// Logger configured to include the mode, date, time and file name in its
+// headers (LstdFlags does, for e.g.), or one configured to use JSONFormat.
+// Timestamps in headers are interpreted as being in UTC (see LUTC).
+type EntryDecoder struct {
+	r       *bufio.Reader
+	follow  bool   // Whether io.EOF is transient, see FollowStream
+	partial string // Trailing line read without its newline, if following
+	cur     *Entry // Entry being assembled, awaiting continuation lines, if any
+}
+
+type decoderOption func(*EntryDecoder)
+
+// FollowStream configures an EntryDecoder to treat io.EOF as the input
+// stream not having been written to yet, as opposed to it having ended (think
+// files being written to as they're read from). Lines read without their
+// trailing newline are retained until the remainder is written out, and so is
+// the last entry, as continuation lines may yet follow; see
+// EntryDecoder.Flush.
+func FollowStream() decoderOption {
+	return func(d *EntryDecoder) {
+		d.follow = true
+	}
+}
+
+// NewEntryDecoder returns a new EntryDecoder reading off of the provided
+// io.Reader.
+func NewEntryDecoder(r io.Reader, options ...decoderOption) *EntryDecoder {
+	d := &EntryDecoder{r: bufio.NewReader(r)}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// Decode decodes the next log entry into the provided Entry, returning io.EOF
+// once the input stream is exhausted. Lines that don't start with a log
+// header are considered to be continuations of the preceding entry's message
+// (think stack traces); ones preceding the very first header are decoded into
+// an entry of their own, one with only the Message set.
+//
+// Decode can be called again after io.EOF is returned, at which point it
+// resumes reading from the underlying io.Reader. For decoders configured
+// using FollowStream, entries are only decoded once complete.
+func (d *EntryDecoder) Decode(entry *Entry) error {
+	return d.decode(entry, !d.follow)
+}
+
+// Flush decodes the next log entry into the provided Entry, treating io.EOF
+// as the end of the input stream regardless of FollowStream. It's to be
+// called repeatedly (until io.EOF is returned) once a followed stream is
+// known to have ended, to decode the entries retained by Decode.
+func (d *EntryDecoder) Flush(entry *Entry) error {
+	return d.decode(entry, true)
+}
+
+// decode decodes the next log entry, treating io.EOF as the end of the input
+// stream if specified.
+func (d *EntryDecoder) decode(entry *Entry, end bool) error {
+	for {
+		line, err := d.r.ReadString('\n')
+		line, d.partial = d.partial+line, ""
+		if err != nil {
+			if err != io.EOF || !end {
+				// The line may be completed by a subsequent read.
+				d.partial = line
+				return err
+			}
+			if line == "" {
+				if d.cur != nil {
+					*entry, d.cur = *d.cur, nil
+					return nil
+				}
+				return err
+			}
+			// The stream ended without a trailing newline, the line is
+			// complete as is.
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		next, ok := parseHeader(line)
+		if !ok {
+			if d.cur == nil {
+				d.cur = &Entry{Message: line}
+			} else {
+				d.cur.Message += "\n" + line
+			}
+			continue
+		}
+
+		if d.cur != nil {
+			*entry, d.cur = *d.cur, &next
+			return nil
+		}
+		d.cur = &next
+	}
+}
+
+// parseHeader parses the log header at the start of the provided line,
+// returning an Entry with the remainder of the line as the message. Lines
+// written out using JSONFormat are parsed in their entirety.
+func parseHeader(line string) (e Entry, ok bool) {
+	if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &e) == nil && (e.Mode != DisabledMode || e.Stack != "") {
+		return e, true
+	}
+
+	matches := headerRegex.FindStringSubmatchIndex(line)
+	if matches == nil {
+		return Entry{}, false
+	}
+	group := func(i int) string {
+		if matches[2*i] < 0 {
+			return ""
+		}
+		return line[matches[2*i]:matches[2*i+1]]
+	}
+	atoi := func(i int) int {
+		n, _ := strconv.Atoi(group(i)) // The regex guarantees well-formed integers, if any.
+		return n
+	}
+
+	switch group(1)[0] {
+	case 'I':
+		e.Mode = InfoMode
+	case 'W':
+		e.Mode = WarnMode
+	case 'E':
+		e.Mode = ErrorMode
+	case 'F':
+		e.Mode = FatalMode
+	case 'D':
+		e.Mode = DebugMode
+	}
+	e.Time = time.Date(2000+atoi(2), time.Month(atoi(3)), atoi(4),
+		atoi(5), atoi(6), atoi(7), atoi(8)*1e3, time.UTC)
+	e.File = group(9)
+	e.Line = atoi(10)
+	e.Message = line[matches[1]:]
+	return e, true
+}
@@ -0,0 +1,80 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ConfigureFromEnv is the environment variable counterpart to RegisterFlags,
+// returning a Logger (configured with the provided options, if any) as per
+// the following environment variables, each of which accept values as the
+// corresponding flag does (see RegisterFlags):
+//
+//	<prefix>_LOG_DIR             -log-dir, for e.g. /path/to/dir
+//	<prefix>_LOG_TO_STDERR       -log-to-stderr, for e.g. true
+//	<prefix>_LOG_MODE            -log-mode, for e.g. warn|error
+//	<prefix>_LOG_FILTER          -log-filter, for e.g. store.go:debug,kv/*.go:warn
+//	<prefix>_LOG_BACKTRACE_AT    -log-backtrace-at, for e.g. store.go:120
+//	<prefix>_LOG_FORMAT          -log-format, for e.g. json
+//
+// If prefix is empty, the variables are named LOG_DIR, LOG_TO_STDERR, etc.
+// Like with RegisterFlags, the log mode, file log modes and tracepoints
+// specified are applied globally. An error is returned for the first
+// improperly formatted variable found (in lexicographic order of the flag
+// names), if any, in which case no Logger is returned. Variables preceding it
+// will have been applied nonetheless. An error is also returned if the
+// options include Writer, see RegisterFlags.
+func ConfigureFromEnv(prefix string, options ...option) (*Logger, error) {
+	fs := flag.NewFlagSet("env", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	l, err := registerFlags(fs, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		name := envVarName(prefix, f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if er := fs.Set(f.Name, value); er != nil {
+			err = errors.New(fmt.Sprintf("%s: %s", name, er))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// envVarName returns the environment variable name corresponding to the
+// provided flag name, for e.g. APP_LOG_BACKTRACE_AT for -log-backtrace-at.
+func envVarName(prefix, flagName string) string {
+	name := strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
@@ -0,0 +1,114 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MultiLine determines how messages spanning multiple lines are written out
+// by Loggers configured using EscapeMessages.
+type MultiLine int
+
+const (
+	// MultiLineVerbatim writes out continuation lines as is.
+	MultiLineVerbatim MultiLine = iota
+	// MultiLineIndent indents continuation lines with a tab, so that they
+	// can't be mistaken for log headers:
+	//
+	//	I180419 06:33:04.606396 fname.go:42] first line
+	//		second line
+	MultiLineIndent
+	// MultiLineEscape escapes newlines as \n, writing out each message as a
+	// single line.
+	MultiLineEscape
+)
+
+// EscapeMessages configures a Logger instance to escape control characters
+// within messages (other than tabs and newlines) as \r, \x1b, \u0085, etc.,
+// and invalid UTF-8 as U+FFFD, with newlines handled as per the provided
+// MultiLine. This way messages including untrusted input can't forge log
+// entries (or terminal escape sequences), and continuation lines can't be
+// misattributed by parsers. It applies to all formats (see OutputFormat)
+// and writers alike; entries are only escaped as they're written out, so
+// hooks (see Hook, Intercept) observe messages as logged.
+func EscapeMessages(m MultiLine) option {
+	return func(l *Logger) {
+		l.escape = true
+		l.multiLine = m
+	}
+}
+
+// Logger.escapeMessage escapes the provided message as per EscapeMessages,
+// if configured to. The trailing newline, if any, is retained as is.
+func (l *Logger) escapeMessage(data string) string {
+	if !l.escape {
+		return data
+	}
+
+	trimmed := strings.TrimSuffix(data, "\n")
+	if !needsEscaping(trimmed, l.multiLine) {
+		return data
+	}
+
+	var b strings.Builder
+	for i, r := range trimmed {
+		switch {
+		case r == '\n' && l.multiLine == MultiLineIndent:
+			b.WriteString("\n\t")
+		case r == '\n' && l.multiLine == MultiLineEscape:
+			b.WriteString(`\n`)
+		case r == '\n' || r == '\t':
+			b.WriteRune(r)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == utf8.RuneError && isInvalidRune(trimmed[i:]):
+			b.WriteRune(utf8.RuneError)
+		case r < 0x80 && unicode.IsControl(r):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if len(trimmed) != len(data) {
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// needsEscaping returns whether the provided message has anything to be
+// escaped, so that the common case doesn't allocate.
+func needsEscaping(s string, m MultiLine) bool {
+	for i, r := range s {
+		if (r == '\n' && m != MultiLineVerbatim) ||
+			(r != '\n' && r != '\t' && unicode.IsControl(r)) ||
+			(r == utf8.RuneError && isInvalidRune(s[i:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// isInvalidRune returns whether the provided string starts with invalid
+// UTF-8, as opposed to an encoded utf8.RuneError.
+func isInvalidRune(s string) bool {
+	_, size := utf8.DecodeRuneInString(s)
+	return size == 1
+}
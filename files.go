@@ -0,0 +1,147 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileInfo describes a log file written out by LogRotationWriter. The
+// program, host, user, pid and start time are recovered from the file name
+// (see generateLogFilename).
+type FileInfo struct {
+	Name      string    // Base name of the file within the directory
+	Program   string    // Name of the program that wrote the file
+	Host      string    // Host the program was running on
+	User      string    // User the program was running as
+	PID       int       // Process ID of the program
+	StartTime time.Time // Time the file was created, in the local time zone
+	Size      int64     // Size of the file in bytes
+	Current   bool      // Whether the <program>.log symlink points to this file
+}
+
+// logFilenameRegex matches file names generated by generateLogFilename,
+// capturing <program>.<host>.<user>, the timestamp and the pid. The program,
+// host and user are matched together as host names (and program names) can
+// contain periods themselves, see parseLogFilename.
+var logFilenameRegex = regexp.MustCompile(
+	`^(.+)\.(\d{4}-\d{2}-\d{2}\.\d{2}:\d{2}:\d{2}(?:\.\d{1,3})?)\.(\d+)\.log$`)
+
+// parseLogFilename is the inverse of generateLogFilename, returning false if
+// the provided name was not one generated by it. Given program names can
+// contain periods (think "log.test"), the provided set of known program names
+// is used to tell apart the program from the host; failing a match there, the
+// program name is taken to be everything up until the first period.
+func parseLogFilename(fname string, programs []string) (fi FileInfo, ok bool) {
+	matches := logFilenameRegex.FindStringSubmatch(fname)
+	if matches == nil {
+		return FileInfo{}, false
+	}
+
+	prefix := matches[1]
+	var prog string
+	for _, p := range programs {
+		if strings.HasPrefix(prefix, p+".") && len(p) > len(prog) {
+			prog = p
+		}
+	}
+	if i := strings.Index(prefix, "."); prog == "" && i > 0 {
+		prog = prefix[:i]
+	}
+
+	// What remains is <host>.<user>; the user name is everything after the
+	// last period, the host everything before it.
+	rest := strings.TrimPrefix(prefix, prog+".")
+	i := strings.LastIndex(rest, ".")
+	if prog == "" || i <= 0 || i == len(rest)-1 {
+		return FileInfo{}, false
+	}
+
+	t, err := time.ParseInLocation("2006-01-02.15:04:05.999", matches[2], time.Local)
+	if err != nil {
+		return FileInfo{}, false
+	}
+	pid, err := strconv.Atoi(matches[3])
+	if err != nil {
+		return FileInfo{}, false
+	}
+
+	return FileInfo{
+		Name:      fname,
+		Program:   prog,
+		Host:      rest[:i],
+		User:      rest[i+1:],
+		PID:       pid,
+		StartTime: t,
+	}, true
+}
+
+// ListLogFiles returns a description of each log file in the specified
+// directory, as written out by LogRotationWriter, ordered by the time the
+// files were created. Files within the directory that don't follow the log
+// file naming scheme (including the <program>.log symlinks) are skipped.
+func ListLogFiles(dir string) ([]FileInfo, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map from program name to the file its <program>.log symlink points to.
+	// The running program is always considered known, even if it hasn't
+	// written to this directory.
+	symlinks := make(map[string]string)
+	programs := []string{program}
+	for _, info := range infos {
+		if info.Mode()&os.ModeSymlink == 0 || !strings.HasSuffix(info.Name(), ".log") {
+			continue
+		}
+		// Best effort, a dangling or unreadable symlink simply means no file
+		// is current for the program.
+		target, _ := os.Readlink(filepath.Join(dir, info.Name()))
+		prog := strings.TrimSuffix(info.Name(), ".log")
+		symlinks[prog] = filepath.Base(target)
+		programs = append(programs, prog)
+	}
+
+	var files []FileInfo
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		fi, ok := parseLogFilename(info.Name(), programs)
+		if !ok {
+			continue
+		}
+
+		fi.Size = info.Size()
+		fi.Current = symlinks[fi.Program] == fi.Name
+		files = append(files, fi)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].StartTime.Equal(files[j].StartTime) {
+			return files[i].StartTime.Before(files[j].StartTime)
+		}
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
@@ -0,0 +1,303 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RegisterFlags registers the following flags with the provided flag.FlagSet,
+// returning a Logger (configured with the provided options, if any) that
+// writes out to wherever -log-dir and -log-to-stderr specify. If neither are
+// specified, logs are discarded.
+//
+//	-log-dir string
+//	      Write log files in this directory.
+//	-log-to-stderr
+//	      Log to standard error.
+//	-log-mode value
+//	      Log mode for logs emitted globally (can be overrode using -log-filter).
+//	-log-filter value
+//	      Comma-separated list of pattern:mode settings for file-filtered logging modes.
+//	-log-backtrace-at value
+//	      Comma-separated list of filename:N settings, when any logging statement at
+//	      the specified locations are executed, a stack trace will be emitted.
+//	-log-format value
+//	      Format log entries are written out in (text|json).
+//
+// The settings take effect as the flags are parsed, the log mode, file log
+// modes and tracepoints specified are applied globally (see SetGlobalLogMode,
+// SetFileLogMode and SetTracePoint). The returned Logger's writer is an
+// *Output, which can be exposed through AdminHandler using AdminOutput. As
+// -log-dir and -log-to-stderr would otherwise have no effect, the options
+// aren't to include Writer; RegisterFlags panics if they do.
+func RegisterFlags(fs *flag.FlagSet, options ...option) *Logger {
+	l, err := registerFlags(fs, options...)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// registerFlags is RegisterFlags, returning an error if the provided options
+// configure the Logger's writer.
+func registerFlags(fs *flag.FlagSet, options ...option) (*Logger, error) {
+	output := NewOutput("", false)
+	l := New(append([]option{Writer(output)}, options...)...)
+	if l.w != output {
+		return nil, errors.New("Writer option specified, logs are to be written out to the Output configured by flags")
+	}
+
+	modeFlag := ModeFlag{Mode: GetGlobalLogMode()}
+	var filterFlag FilterFlag
+	var backtraceFlag BacktraceFlag
+
+	fs.Var(&outputDirFlag{output}, "log-dir",
+		"Write log files in this directory.")
+	fs.Var(&outputStderrFlag{output}, "log-to-stderr",
+		"Log to standard error.")
+	fs.Var(&applyFlag{&modeFlag, func() {
+		SetGlobalLogMode(modeFlag.Mode)
+	}}, "log-mode",
+		"Log mode for logs emitted globally (can be overrode using -log-filter).")
+	fs.Var(&applyFlag{&filterFlag, func() {
+		for _, fm := range filterFlag {
+			SetFileLogMode(fm.File, fm.Mode)
+		}
+	}}, "log-filter",
+		"Comma-separated list of pattern:mode settings for file-filtered logging modes.")
+	fs.Var(&applyFlag{&backtraceFlag, func() {
+		for _, tp := range backtraceFlag {
+			SetTracePoint(tp)
+		}
+	}}, "log-backtrace-at",
+		"Comma-separated list of filename:N settings, when any logging statement at "+
+			"the specified locations are executed, a stack trace will be emitted.")
+	fs.Var(&formatFlag{l}, "log-format",
+		"Format log entries are written out in (text|json).")
+
+	return l, nil
+}
+
+// ModeFlag is a flag.Value for log modes, of the form info|warn (see
+// ParseMode).
+type ModeFlag struct {
+	Mode Mode
+	set  bool
+}
+
+// IsSet returns whether or not the flag was specified.
+func (f *ModeFlag) IsSet() bool {
+	return f.set
+}
+
+func (f *ModeFlag) String() string {
+	return f.Mode.String()
+}
+
+// Set implements flag.Value.
+func (f *ModeFlag) Set(value string) error {
+	m, err := ParseMode(value)
+	if err != nil {
+		return err
+	}
+	f.Mode = m
+	f.set = true
+	return nil
+}
+
+// FileMode is a file log mode, see SetFileLogMode.
+type FileMode struct {
+	File string
+	Mode Mode
+}
+
+// FilterFlag is a flag.Value for a comma-separated list of file log modes,
+// of the form fname.go:mode or kv/*.go:mode (see SetFileLogMode).
+type FilterFlag []FileMode
+
+func (f *FilterFlag) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, fm := range *f {
+		if i != 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(fmt.Sprintf("%s:%s", fm.File, fm.Mode))
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
+var fileNameRegex = regexp.MustCompile(`^[\w]+\.go$`)
+
+// filePatternRegex matches file name patterns (see SetFileLogMode), made up
+// of path components and path.Match metacharacters.
+var filePatternRegex = regexp.MustCompile(`^([\w\-.*?\[\]^]+/)*[\w\-.*?\[\]^]+\.go$`)
+
+// Set implements flag.Value.
+func (f *FilterFlag) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		s := strings.Split(s, ":")
+		if len(s) != 2 {
+			return errors.New(
+				fmt.Sprintf("Improperly formatted filter: %s, expected fname.go:mode", s))
+		}
+
+		fname, mode := s[0], s[1]
+		if !filePatternRegex.MatchString(fname) {
+			return errors.New(
+				fmt.Sprintf("Expected filename '%s' to match the regex '%s'", fname, filePatternRegex))
+		}
+		if _, err := path.Match(fname, ""); err != nil {
+			return errors.New(
+				fmt.Sprintf("Improperly formatted pattern: %s, %s", fname, err))
+		}
+
+		fmode, err := ParseMode(mode)
+		if err != nil {
+			return err
+		}
+		*f = append(*f, FileMode{File: fname, Mode: fmode})
+	}
+	return nil
+}
+
+// BacktraceFlag is a flag.Value for a comma-separated list of tracepoints, of
+// the form fname.go:42 (see SetTracePoint).
+type BacktraceFlag []string
+
+func (f *BacktraceFlag) String() string {
+	return fmt.Sprint(*f)
+}
+
+var lineNumberRegex = regexp.MustCompile(`^[\d]+$`)
+
+// Set implements flag.Value.
+func (f *BacktraceFlag) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		s := strings.Split(s, ":")
+		if len(s) != 2 {
+			return errors.New(
+				fmt.Sprintf("Improperly formatted tracepoint: %s, expected fname.go:line", s))
+		}
+
+		fname, lnumber := s[0], s[1]
+		if !fileNameRegex.MatchString(fname) {
+			return errors.New(
+				fmt.Sprintf("Expected filename '%s' to match the regex '%s'", fname, fileNameRegex))
+		}
+		if !lineNumberRegex.MatchString(lnumber) {
+			return errors.New(
+				fmt.Sprintf("Expected line number '%s' to match the regex '%s'", lnumber, lineNumberRegex))
+		}
+		*f = append(*f, fmt.Sprintf("%s:%s", fname, lnumber))
+	}
+	return nil
+}
+
+// applyFlag wraps a flag.Value, invoking apply after each successful Set.
+type applyFlag struct {
+	flag.Value
+	apply func()
+}
+
+func (f *applyFlag) String() string {
+	if f.Value == nil {
+		return "" // Zero value, as used by flag.PrintDefaults.
+	}
+	return f.Value.String()
+}
+
+func (f *applyFlag) Set(value string) error {
+	if err := f.Value.Set(value); err != nil {
+		return err
+	}
+	f.apply()
+	return nil
+}
+
+// formatFlag is a flag.Value configuring the format a Logger encodes log
+// entries in.
+type formatFlag struct {
+	l *Logger
+}
+
+func (f *formatFlag) String() string {
+	if f.l == nil {
+		return "" // Zero value, as used by flag.PrintDefaults.
+	}
+	return f.l.getFormat().String()
+}
+
+func (f *formatFlag) Set(value string) error {
+	format, err := ParseFormat(value)
+	if err != nil {
+		return err
+	}
+	f.l.setFormat(format)
+	return nil
+}
+
+// outputDirFlag is a flag.Value configuring the directory an Output writes
+// log files to.
+type outputDirFlag struct {
+	o *Output
+}
+
+func (f *outputDirFlag) String() string {
+	if f.o == nil {
+		return "" // Zero value, as used by flag.PrintDefaults.
+	}
+	return f.o.Dir()
+}
+
+func (f *outputDirFlag) Set(value string) error {
+	f.o.SetDir(value)
+	return nil
+}
+
+// outputStderrFlag is a boolean flag.Value configuring whether or not an
+// Output writes to standard error.
+type outputStderrFlag struct {
+	o *Output
+}
+
+func (f *outputStderrFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (f *outputStderrFlag) String() string {
+	if f.o == nil {
+		return "false" // Zero value, as used by flag.PrintDefaults.
+	}
+	return strconv.FormatBool(f.o.ToStderr())
+}
+
+func (f *outputStderrFlag) Set(value string) error {
+	toStderr, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	f.o.SetToStderr(toStderr)
+	return nil
+}
@@ -15,6 +15,10 @@
 package log
 
 import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -23,6 +27,7 @@ import (
 type tracePointMap map[string]struct{}
 type fileModeMap map[string]Mode
 type gstateT struct {
+	gen          uint64 // Bumped whenever the state changes, see resolveSite; first for 64-bit alignment
 	gmode        atomic.Value
 	tracePointMu struct {
 		sync.Mutex
@@ -47,6 +52,7 @@ func init() {
 // outside what's included in the mode is thereby suppressed.
 func SetGlobalLogMode(m Mode) {
 	gstate.gmode.Store(m)
+	atomic.AddUint64(&gstate.gen, 1)
 }
 
 // GetGlobalLogMode gets the currently set global log mode.
@@ -66,8 +72,9 @@ func SetTracePoint(tp string) {
 	for tp := range ma {
 		mb[tp] = struct{}{} // Copy all data from the current object to the new one.
 	}
-	mb[tp] = struct{}{}             // Do the update that we need.
-	gstate.tracePointMu.m.Store(mb) // Atomically replace the current object with the new one.
+	mb[tp] = struct{}{}              // Do the update that we need.
+	gstate.tracePointMu.m.Store(mb)  // Atomically replace the current object with the new one.
+	atomic.AddUint64(&gstate.gen, 1) // Invalidate cached call site decisions.
 	// At this point all new readers start working with the new version.
 	// The old version will be garbage collected once the existing readers
 	// (if any) are done with it.
@@ -84,8 +91,9 @@ func ResetTracePoint(tp string) {
 	for tp := range ma {
 		mb[tp] = struct{}{} // Copy all data from the current object to the new one.
 	}
-	delete(mb, tp)                  // Do the update that we need.
-	gstate.tracePointMu.m.Store(mb) // Atomically replace the current object with the new one.
+	delete(mb, tp)                   // Do the update that we need.
+	gstate.tracePointMu.m.Store(mb)  // Atomically replace the current object with the new one.
+	atomic.AddUint64(&gstate.gen, 1) // Invalidate cached call site decisions.
 	// At this point all new readers start working with the new version.
 	// The old version will be garbage collected once the existing readers
 	// (if any) are done with it.
@@ -99,8 +107,24 @@ func GetTracePoint(tp string) (tpenabled bool) {
 	return ok
 }
 
+// getTracePoints returns the currently enabled tracepoints, sorted.
+func getTracePoints() []string {
+	tpmap := gstate.tracePointMu.m.Load().(tracePointMap)
+	tps := make([]string, 0, len(tpmap))
+	for tp := range tpmap {
+		tps = append(tps, tp)
+	}
+	sort.Strings(tps)
+	return tps
+}
+
 // SetFileLogMode sets the log mode for the provided filename. Subsequent
-// logging statements within the file get filtered accordingly.
+// logging statements within the file get filtered accordingly. The filename
+// can also be a pattern (see path.Match) matched against the trailing path
+// components of the files logging statements are in, for e.g. kv/*.go for
+// the files in any directory named kv. Where multiple apply, the log mode
+// set for the base name of the file takes precedence, followed by that for
+// the longest matching pattern.
 func SetFileLogMode(fname string, m Mode) {
 	gstate.fileModeMu.Lock()                       // Synchronize with other potential writers.
 	ma := gstate.fileModeMu.m.Load().(fileModeMap) // Load current value of the map.
@@ -108,8 +132,9 @@ func SetFileLogMode(fname string, m Mode) {
 	for fname, m := range ma {
 		mb[fname] = m // Copy all data from the current object to the new one.
 	}
-	mb[fname] = m                 // Do the update that we need.
-	gstate.fileModeMu.m.Store(mb) // Atomically replace the current object with the new one.
+	mb[fname] = m                    // Do the update that we need.
+	gstate.fileModeMu.m.Store(mb)    // Atomically replace the current object with the new one.
+	atomic.AddUint64(&gstate.gen, 1) // Invalidate cached call site decisions.
 	// At this point all new readers start working with the new version.
 	// The old version will be garbage collected once the existing readers
 	// (if any) are done with it.
@@ -123,6 +148,41 @@ func GetFileLogMode(fname string) (m Mode, ok bool) {
 	return m, ok
 }
 
+// fileLogMode returns the log mode for the provided file (fully qualified),
+// as set using SetFileLogMode, if any.
+func fileLogMode(file string) (m Mode, ok bool) {
+	fmmap := gstate.fileModeMu.m.Load().(fileModeMap)
+	if len(fmmap) == 0 {
+		return DisabledMode, false
+	}
+	if m, ok = fmmap[filepath.Base(file)]; ok {
+		return m, ok
+	}
+
+	var matched string
+	for pattern, pm := range fmmap {
+		if len(pattern) < len(matched) || (len(pattern) == len(matched) && pattern > matched) {
+			continue
+		}
+		if matchFilePattern(pattern, file) {
+			matched, m, ok = pattern, pm, true
+		}
+	}
+	return m, ok
+}
+
+// matchFilePattern returns whether the provided pattern (see path.Match)
+// matches the trailing path components of the provided file, as many as
+// there are in the pattern.
+func matchFilePattern(pattern, file string) bool {
+	i := len(file)
+	for n := strings.Count(pattern, "/") + 1; n > 0 && i >= 0; n-- {
+		i = strings.LastIndexByte(file[:i], '/')
+	}
+	matched, _ := path.Match(pattern, file[i+1:])
+	return matched
+}
+
 // ResetFileLogMode resets the log mode for the provided filename. Subsequent
 // logging statements within the file get filtered as per the global log mode.
 func ResetFileLogMode(fname string) {
@@ -132,10 +192,65 @@ func ResetFileLogMode(fname string) {
 	for fname, m := range ma {
 		mb[fname] = m // Copy all data from the current object to the new one.
 	}
-	delete(mb, fname)             // Do the update that we need.
-	gstate.fileModeMu.m.Store(mb) // Atomically replace the current object with the new one.
+	delete(mb, fname)                // Do the update that we need.
+	gstate.fileModeMu.m.Store(mb)    // Atomically replace the current object with the new one.
+	atomic.AddUint64(&gstate.gen, 1) // Invalidate cached call site decisions.
 	// At this point all new readers start working with the new version.
 	// The old version will be garbage collected once the existing readers
 	// (if any) are done with it.
 	gstate.fileModeMu.Unlock()
 }
+
+// getFileLogModes returns a copy of the currently set file log modes.
+func getFileLogModes() map[string]Mode {
+	fmmap := gstate.fileModeMu.m.Load().(fileModeMap)
+	fmodes := make(map[string]Mode, len(fmmap))
+	for fname, m := range fmmap {
+		fmodes[fname] = m
+	}
+	return fmodes
+}
+
+// gstateSnapshot is a point-in-time copy of the global state, see
+// snapshotGState.
+type gstateSnapshot struct {
+	gmode       Mode
+	tracePoints tracePointMap
+	fileModes   fileModeMap
+}
+
+// snapshotGState captures the current global state, to be restored later
+// using restoreGState. Given the maps are copied on write, they're captured
+// as is.
+func snapshotGState() gstateSnapshot {
+	return gstateSnapshot{
+		gmode:       GetGlobalLogMode(),
+		tracePoints: gstate.tracePointMu.m.Load().(tracePointMap),
+		fileModes:   gstate.fileModeMu.m.Load().(fileModeMap),
+	}
+}
+
+// restoreGState restores the global state captured using snapshotGState.
+func restoreGState(s gstateSnapshot) {
+	SetGlobalLogMode(s.gmode)
+
+	gstate.tracePointMu.Lock()
+	gstate.tracePointMu.m.Store(s.tracePoints)
+	gstate.tracePointMu.Unlock()
+
+	gstate.fileModeMu.Lock()
+	gstate.fileModeMu.m.Store(s.fileModes)
+	gstate.fileModeMu.Unlock()
+
+	atomic.AddUint64(&gstate.gen, 1)
+}
+
+// SaveGlobalState captures the global state (the global log mode, file log
+// modes and tracepoints), returning a function that restores it. It's
+// intended for tests that change the global state, see the logtest package.
+func SaveGlobalState() (restore func()) {
+	s := snapshotGState()
+	return func() {
+		restoreGState(s)
+	}
+}
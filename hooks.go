@@ -0,0 +1,109 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// interceptor wraps a function registered using Intercept, so that it can be
+// identified when unregistering.
+type interceptor struct {
+	f func(Entry)
+}
+
+var interceptors struct {
+	sync.Mutex              // Synchronizes writers
+	v          atomic.Value // type: []*interceptor, copied on write
+}
+
+func init() {
+	interceptors.v.Store([]*interceptor(nil))
+}
+
+// Intercept registers the provided function to be invoked with every entry
+// logged through any Logger that isn't filtered out (by log modes or rate
+// limits), before it's written out. It's invoked synchronously by the
+// logging goroutine, and is thus expected to be fast (and to not log through
+// the same Logger). The returned function unregisters it.
+//
+//	unregister := log.Intercept(func(e log.Entry) {
+//	    if e.Mode == log.ErrorMode {
+//	        alert(e.File, e.Line, e.Message)
+//	    }
+//	})
+//	defer unregister()
+func Intercept(f func(Entry)) (unregister func()) {
+	i := &interceptor{f: f}
+
+	interceptors.Lock()
+	ia := interceptors.v.Load().([]*interceptor)
+	ib := make([]*interceptor, len(ia), len(ia)+1)
+	copy(ib, ia)
+	interceptors.v.Store(append(ib, i))
+	interceptors.Unlock()
+
+	return func() {
+		interceptors.Lock()
+		defer interceptors.Unlock()
+
+		ia := interceptors.v.Load().([]*interceptor)
+		ib := make([]*interceptor, 0, len(ia))
+		for _, j := range ia {
+			if j != i {
+				ib = append(ib, j)
+			}
+		}
+		interceptors.v.Store(ib)
+	}
+}
+
+// Hook configures a Logger instance to invoke the provided function with
+// every entry logged through it that isn't filtered out, as is done for
+// functions registered using Intercept. It can be specified multiple times.
+func Hook(f func(Entry)) option {
+	return func(l *Logger) {
+		l.hooks = append(l.hooks, f)
+	}
+}
+
+// Logger.intercept invokes the Logger's hooks and the registered
+// interceptors, if any, with the provided entry.
+func (l *Logger) intercept(lmode Mode, t time.Time, file string, line int, data string) {
+	is := interceptors.v.Load().([]*interceptor)
+	if len(l.hooks) == 0 && len(is) == 0 {
+		return
+	}
+
+	if l.flag&LUTC != 0 {
+		t = t.UTC()
+	}
+	e := Entry{
+		Mode:    lmode,
+		Time:    t,
+		File:    l.fileName(file),
+		Line:    line,
+		Message: strings.TrimSuffix(data, "\n"),
+	}
+	for _, f := range l.hooks {
+		f(e)
+	}
+	for _, i := range is {
+		i.f(e)
+	}
+}
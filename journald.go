@@ -0,0 +1,306 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// journaldSocket is where systemd-journald listens for entries sent using
+// its native protocol.
+const journaldSocket = "/run/systemd/journal/socket"
+
+// JournaldWriter is an io.Writer that sends log entries to systemd-journald,
+// see NewJournaldWriter.
+type JournaldWriter struct {
+	socket string
+	fields []byte // Additional fields, pre-encoded
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+type journaldOption func(*JournaldWriter)
+
+// JournaldSocket configures the unix datagram socket entries are sent to,
+// defaulting to /run/systemd/journal/socket.
+func JournaldSocket(path string) journaldOption {
+	return func(w *JournaldWriter) {
+		w.socket = path
+	}
+}
+
+// journaldFieldRegex matches valid journal field names. Those starting with
+// an underscore are reserved for journald itself.
+var journaldFieldRegex = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_]*$`)
+
+// JournaldField configures an additional field every entry is sent with,
+// for e.g. JournaldField("SERVICE", "kv"). Field names are to consist of
+// uppercase letters, digits and underscores; invalid ones are ignored.
+func JournaldField(name, value string) journaldOption {
+	return func(w *JournaldWriter) {
+		if journaldFieldRegex.MatchString(name) {
+			w.fields = appendJournaldField(w.fields, name, value)
+		}
+	}
+}
+
+// NewJournaldWriter returns an io.Writer that sends each log entry written
+// out to it to systemd-journald using its native protocol, with the
+// following fields:
+//
+//	MESSAGE               The message, followed by the tracepoint backtrace if any
+//	PRIORITY              The mode, mapped to the syslog severity (see SyslogWriter)
+//	CODE_FILE, CODE_LINE  The file name and line number of the logging statement
+//	CODE_FUNC             The function the logging statement is in, if found
+//	SYSLOG_IDENTIFIER     The program name
+//
+// In addition to any configured using JournaldField. This allows for, say:
+//
+//	$ journalctl -p err CODE_FILE=store.go
+//
+// Entries too large to be sent as a single datagram are written out to a
+// sealed memfd instead (as done by libsystemd), whose file descriptor is sent
+// over. Where memfds aren't available, an unlinked temporary file is used.
+//
+// Like EntryDecoder, it expects to be written to by a Logger configured to
+// include the mode, date, time and file name in its headers (LstdFlags does,
+// for e.g.), each write corresponding to a single entry. The function is
+// found by walking the stack of the writing goroutine, so CODE_FUNC is only
+// included for entries written out synchronously by the logging statement.
+// When written to directly by a Logger, the function is looked up using the
+// fully qualified file name of the logging statement; otherwise (think
+// MultiWriter) using the file name included in the header.
+func NewJournaldWriter(options ...journaldOption) (*JournaldWriter, error) {
+	w := &JournaldWriter{socket: journaldSocket}
+	for _, option := range options {
+		option(w)
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: w.socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	w.conn = conn
+	return w, nil
+}
+
+// Write implements io.Writer.
+func (w *JournaldWriter) Write(b []byte) (n int, err error) {
+	return w.writeEntry(b, "")
+}
+
+// writeEntry implements entryWriter.
+func (w *JournaldWriter) writeEntry(b []byte, file string) (n int, err error) {
+	e, err := decodeEntry(b)
+	if err != nil {
+		return 0, err
+	}
+	if file == "" {
+		file = e.File
+	}
+
+	var msg []byte
+	msg = appendJournaldField(msg, "MESSAGE", e.messageWithStack())
+	msg = appendJournaldField(msg, "PRIORITY", strconv.Itoa(syslogSeverity(e.Mode)))
+	if e.File != "" {
+		msg = appendJournaldField(msg, "CODE_FILE", e.File)
+		msg = appendJournaldField(msg, "CODE_LINE", strconv.Itoa(e.Line))
+		if fn := callerFunc(file, e.Line); fn != "" {
+			msg = appendJournaldField(msg, "CODE_FUNC", fn)
+		}
+	}
+	msg = appendJournaldField(msg, "SYSLOG_IDENTIFIER", program)
+	msg = append(msg, w.fields...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err = w.conn.Write(msg)
+	if isMessageTooLarge(err) {
+		err = w.writeFile(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// isMessageTooLarge returns whether the provided error indicates a datagram
+// being too large to be sent.
+func isMessageTooLarge(err error) bool {
+	var errno syscall.Errno
+	return errors.As(err, &errno) && (errno == syscall.EMSGSIZE || errno == syscall.ENOBUFS)
+}
+
+// writeFile writes out the provided entry to a memfd (or failing that, an
+// unlinked temporary file), sending over its file descriptor.
+func (w *JournaldWriter) writeFile(msg []byte) error {
+	f, err := journaldMemfd(msg)
+	if err != nil {
+		f, err = journaldTempFile(msg)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The net package doesn't allow for sending control messages over
+	// connected datagram sockets, so we do so directly.
+	rc, err := w.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	oob := syscall.UnixRights(int(f.Fd()))
+	if werr := rc.Write(func(fd uintptr) bool {
+		err = syscall.Sendmsg(int(fd), nil, oob, nil, 0)
+		return err != syscall.EAGAIN
+	}); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// Flags and fcntl commands used to create sealed memfds, as defined in
+// <linux/memfd.h> and <linux/fcntl.h> (the syscall package doesn't).
+const (
+	mfdCloexec      = 0x1
+	mfdAllowSealing = 0x2
+
+	fAddSeals   = 1024 + 9
+	fGetSeals   = 1024 + 10
+	fSealSeal   = 0x1
+	fSealShrink = 0x2
+	fSealGrow   = 0x4
+	fSealWrite  = 0x8
+)
+
+// sysMemfdCreate is the memfd_create system call number, per architecture.
+// The syscall package only defines it for some.
+var sysMemfdCreate = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"mips":     4354,
+	"mipsle":   4354,
+	"mips64":   5314,
+	"mips64le": 5314,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+}
+
+// journaldMemfd returns a memfd containing the provided entry, sealed against
+// further modifications as journald expects.
+func journaldMemfd(msg []byte) (*os.File, error) {
+	trap, ok := sysMemfdCreate[runtime.GOARCH]
+	if !ok {
+		return nil, syscall.ENOSYS
+	}
+	name, err := syscall.BytePtrFromString("journal-data")
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	f := os.NewFile(fd, "journal-data")
+	if _, err := f.Write(msg); err != nil {
+		f.Close()
+		return nil, err
+	}
+	seals := fSealShrink | fSealGrow | fSealWrite | fSealSeal
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), fAddSeals, uintptr(seals)); errno != 0 {
+		f.Close()
+		return nil, errno
+	}
+	return f, nil
+}
+
+// journaldTempFile returns an unlinked temporary file containing the
+// provided entry, created in /dev/shm if possible.
+func journaldTempFile(msg []byte) (*os.File, error) {
+	f, err := ioutil.TempFile("/dev/shm", "journal.")
+	if err != nil {
+		if f, err = ioutil.TempFile("", "journal."); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Write(msg); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// appendJournaldField appends the provided field as per the journald native
+// protocol. Values containing newlines are length-prefixed.
+func appendJournaldField(b []byte, name, value string) []byte {
+	if !strings.ContainsRune(value, '\n') {
+		return append(append(append(append(b, name...), '='), value...), '\n')
+	}
+
+	b = append(append(b, name...), '\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	return append(append(append(b, size[:]...), value...), '\n')
+}
+
+// callerFunc returns the fully qualified name of the function the logging
+// statement at the provided file and line is in, by walking the current
+// goroutine's stack. The file is either fully qualified, or as it appears in
+// log headers (see Flags), in which case it's matched against the trailing
+// path components.
+func callerFunc(file string, line int) string {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if frame.Line == line && (frame.File == file || strings.HasSuffix(frame.File, "/"+file)) {
+			return frame.Function
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// Close closes the connection to journald.
+func (w *JournaldWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.Close()
+}
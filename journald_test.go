@@ -0,0 +1,119 @@
+//go:build linux
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestJournaldWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journald")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	writer, err := NewJournaldWriter(JournaldSocket(socket), JournaldField("SERVICE", "kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	logger := New(Writer(writer))
+
+	// XXX(irfansharif): This test depends on the exact difference in line
+	// numbers between the call to caller and the logging statement below.
+	_, line := caller(0)
+	logger.Error("error\nwith continuation")
+
+	b := make([]byte, 1<<16)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := appendJournaldField(nil, "MESSAGE", "error\nwith continuation")
+	expected = append(expected, "PRIORITY=3\nCODE_FILE=journald_test.go\n"...)
+	expected = appendJournaldField(expected, "CODE_LINE", strconv.Itoa(line+1))
+	expected = append(expected, "CODE_FUNC=github.com/irfansharif/log.TestJournaldWriter\n"...)
+	expected = append(expected, "SYSLOG_IDENTIFIER="+program+"\nSERVICE=kv\n"...)
+	if !bytes.Equal(b[:n], expected) {
+		t.Errorf("expected %q, got %q", expected, b[:n])
+	}
+
+	// Tracepoint backtraces are sent as part of the entry they precede.
+	_, line = caller(0)
+	tp := "journald_test.go:" + strconv.Itoa(line+3)
+	SetTracePoint(tp)
+	logger.Warn("traced")
+	ResetTracePoint(tp)
+
+	if n, err = conn.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	prefix := "MESSAGE\n"
+	expected = []byte("PRIORITY=4\nCODE_FILE=journald_test.go\nCODE_LINE=" + strconv.Itoa(line+3) + "\n")
+	if !bytes.HasPrefix(b[:n], []byte(prefix)) || !bytes.Contains(b[:n], []byte("traced\ngoroutine ")) || !bytes.Contains(b[:n], expected) {
+		t.Errorf("expected traced entry with its backtrace, got %q", b[:n])
+	}
+
+	// Entries too large for a single datagram are sent over as a file.
+	large := strings.Repeat("x", 1<<20)
+	logger.Info(large)
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := conn.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected a single control message, got: %v (err: %v)", msgs, err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("expected a single file descriptor, got: %v (err: %v)", fds, err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+	if seals, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), fGetSeals, 0); errno != 0 || seals&fSealWrite == 0 {
+		t.Errorf("expected a sealed memfd, got seals: %#x (err: %v)", seals, errno)
+	}
+	f.Seek(0, 0)
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(contents, []byte("MESSAGE="+large+"\n")) {
+		t.Errorf("expected large entry to be sent over, got %d bytes", len(contents))
+	}
+
+	// Files are matched by full path, or by trailing path components.
+	_, file, line, _ := runtime.Caller(0)
+	for _, tc := range []struct {
+		file, expected string
+	}{
+		{file, "github.com/irfansharif/log.TestJournaldWriter"},
+		{"journald_test.go", "github.com/irfansharif/log.TestJournaldWriter"},
+		{"_test.go", ""},
+		{"/elsewhere/journald_test.go", ""},
+	} {
+		if fn := callerFunc(tc.file, line+9); fn != tc.expected {
+			t.Errorf("expected caller %q for %s, got %q", tc.expected, tc.file, fn)
+		}
+	}
+}
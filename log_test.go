@@ -1,11 +1,24 @@
 package log
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	stdlog "log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSetGetGlobalPCMode(t *testing.T) {
@@ -106,6 +119,41 @@ func TestDebugModeEnableDisable(t *testing.T) {
 	}
 }
 
+func TestFileLogModePatterns(t *testing.T) {
+	var filter FilterFlag
+	if err := filter.Set("kv/*.go:debug,kv/store.go:warn,raft.go:error"); err != nil {
+		t.Fatal(err)
+	}
+	for _, fm := range filter {
+		SetFileLogMode(fm.File, fm.Mode)
+		defer ResetFileLogMode(fm.File)
+	}
+
+	for _, tc := range []struct {
+		file     string
+		expected Mode
+		ok       bool
+	}{
+		{"/src/kv/replica.go", DebugMode, true},
+		{"/src/kv/store.go", WarnMode, true},
+		{"/src/kv/raft.go", ErrorMode, true},
+		{"/src/sql/kv/replica.go", DebugMode, true},
+		{"/src/kvserver/replica.go", DisabledMode, false},
+		{"replica.go", DisabledMode, false},
+	} {
+		if m, ok := fileLogMode(tc.file); m != tc.expected || ok != tc.ok {
+			t.Errorf("expected file log mode %s (%t) for %s, got %s (%t)", tc.expected, tc.ok, tc.file, m, ok)
+		}
+	}
+
+	for _, value := range []string{"kv/[.go:debug", "kv/*.g:debug", "/kv/*.go:debug"} {
+		var filter FilterFlag
+		if err := filter.Set(value); err == nil {
+			t.Errorf("expected error parsing %s", value)
+		}
+	}
+}
+
 func TestEnableTracePoint(t *testing.T) {
 	SetGlobalLogMode(DisabledMode)
 	defer SetGlobalLogMode(DefaultMode)
@@ -158,3 +206,1220 @@ func TestEnableTracePoint(t *testing.T) {
 		}
 	}
 }
+
+func TestEnableTracePointJSON(t *testing.T) {
+	defer SetGlobalLogMode(DefaultMode)
+
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(buffer), OutputFormat(JSONFormat))
+	file, line := caller(0)
+	tp := fmt.Sprintf("%s:%d", filepath.Base(file), line+6)
+	SetTracePoint(tp)
+	defer ResetTracePoint(tp)
+	for _, mode := range []Mode{InfoMode, DisabledMode} {
+		SetGlobalLogMode(mode)
+		logger.Info("info")
+	}
+
+	// Every line is to be a JSON object, with the backtrace included in the
+	// entry, and on its own for the one filtered out.
+	var entries []Entry
+	for _, l := range strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n") {
+		var e Entry
+		if err := json.Unmarshal([]byte(l), &e); err != nil {
+			t.Fatalf("expected JSON formatted entry, got %s: %s", l, err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %s", len(entries), buffer.String())
+	}
+	for i, mode := range []Mode{InfoMode, DisabledMode} {
+		e := entries[i]
+		if e.Mode != mode || e.Line != line+6 ||
+			!strings.Contains(e.Stack, "github.com/irfansharif/log.TestEnableTracePointJSON") {
+			t.Errorf("unexpected entry: %+v", e)
+		}
+	}
+}
+
+func TestListLogFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writer := LogRotationWriter(dir, 50<<20 /* 50 MiB */)
+	logger := New(Writer(writer))
+	logger.Info("info")
+
+	files, err := ListLogFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected a single log file, got: %v", files)
+	}
+
+	fi := files[0]
+	if fi.Program != program || fi.Host != hostname || fi.User != username || fi.PID != pid {
+		t.Errorf("expected log file for %s.%s.%s.%d, got: %+v", program, hostname, username, pid, fi)
+	}
+	if !fi.Current {
+		t.Errorf("expected %s to be the current log file", fi.Name)
+	}
+	if fi.Size == 0 {
+		t.Errorf("expected %s to be non-empty", fi.Name)
+	}
+	if d := time.Since(fi.StartTime); d < 0 || d > time.Minute {
+		t.Errorf("unexpected start time %s for %s", fi.StartTime, fi.Name)
+	}
+
+	// Files created in quick succession don't truncate one another, and are
+	// listed in the order they were created in.
+	rotation := writer.(*logRotationWriter)
+	for i := 0; i < 5; i++ {
+		rotation.Close()
+		fmt.Fprintf(rotation, "%d\n", i)
+	}
+	rotation.Close()
+	if files, err = ListLogFiles(dir); err != nil || len(files) != 6 {
+		t.Fatalf("expected six log files, got: %v (err: %v)", files, err)
+	}
+	for i, fi := range files[1:] {
+		if b, err := ioutil.ReadFile(filepath.Join(dir, fi.Name)); err != nil || string(b) != fmt.Sprintf("%d\n", i) {
+			t.Errorf("expected %s to contain %d, got: %q (err: %v)", fi.Name, i, b, err)
+		}
+	}
+}
+
+func TestNewlineTerminatedEntries(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(buffer), Flags(Lmode))
+	logger.Infof("infof")
+	logger.Infof("infof with newline\n")
+	logger.Info("info")
+	if expected := "I infof\nI infof with newline\nI info\n"; buffer.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buffer.String())
+	}
+}
+
+func TestEntryDecoder(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(buffer))
+	logger.Info("info")
+	logger.Warnf("warnf\nwith continuation")
+	logger.Errorf("errorf")
+
+	var entries []Entry
+	decoder := NewEntryDecoder(buffer)
+	for {
+		var entry Entry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	expected := []struct {
+		mode    Mode
+		message string
+	}{
+		{InfoMode, "info"},
+		{WarnMode, "warnf\nwith continuation"},
+		{ErrorMode, "errorf"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got: %v", len(expected), entries)
+	}
+	for i, e := range expected {
+		if entries[i].Mode != e.mode || entries[i].Message != e.message {
+			t.Errorf("expected (%s, %q), got (%s, %q)", e.mode, e.message, entries[i].Mode, entries[i].Message)
+		}
+		if entries[i].File != "log_test.go" {
+			t.Errorf("expected entry to be logged from log_test.go, got: %s", entries[i].File)
+		}
+		if d := time.Since(entries[i].Time); d < 0 || d > time.Minute {
+			t.Errorf("unexpected timestamp %s", entries[i].Time)
+		}
+	}
+}
+
+func TestEntryDecoderFollowStream(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	decoder := NewEntryDecoder(buffer, FollowStream())
+	var entry Entry
+
+	// Neither partially written lines, nor entries that may yet have
+	// continuation lines, are to be decoded.
+	buffer.WriteString("I180419 06:33:04.606396 fname.go:42] fir")
+	if err := decoder.Decode(&entry); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v (%v)", err, entry)
+	}
+	buffer.WriteString("st\ncontinuation\n")
+	if err := decoder.Decode(&entry); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v (%v)", err, entry)
+	}
+
+	buffer.WriteString("W180419 06:33:05.606396 fname.go:43] second\n")
+	if err := decoder.Decode(&entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Mode != InfoMode || entry.Line != 42 || entry.Message != "first\ncontinuation" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if err := decoder.Decode(&entry); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v (%v)", err, entry)
+	}
+
+	// Retained entries are decoded once the stream is known to have ended.
+	if err := decoder.Flush(&entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Mode != WarnMode || entry.Message != "second" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if err := decoder.Flush(&entry); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v (%v)", err, entry)
+	}
+}
+func TestAdminHandler(t *testing.T) {
+	defer SetGlobalLogMode(DefaultMode)
+	defer ResetFileLogMode("f.go")
+	defer ResetTracePoint("t.go:42")
+
+	output := NewOutput("", false)
+	server := httptest.NewServer(http.StripPrefix("/debug/log", AdminHandler(AdminOutput(output))))
+	defer server.Close()
+
+	request := func(method, path, body string) (AdminState, int) {
+		req, err := http.NewRequest(method, server.URL+"/debug/log"+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var state AdminState
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return state, resp.StatusCode
+	}
+
+	if _, code := request("PUT", "/mode", "warn|error"); code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if m := GetGlobalLogMode(); m != WarnMode|ErrorMode {
+		t.Errorf("expected global log mode %s, got %s", WarnMode|ErrorMode, m)
+	}
+	if _, code := request("PUT", "/mode", "verbose"); code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, code)
+	}
+
+	request("PUT", "/files/f.go", "debug")
+	if m, ok := GetFileLogMode("f.go"); !ok || m != DebugMode {
+		t.Errorf("expected file log mode %s for f.go, got %s", DebugMode, m)
+	}
+	request("PUT", "/tracepoints/t.go:42", "")
+	if !GetTracePoint("t.go:42") {
+		t.Errorf("expected tracepoint t.go:42 to be enabled")
+	}
+
+	contains := func(tps []string, tp string) bool {
+		for _, t := range tps {
+			if t == tp {
+				return true
+			}
+		}
+		return false
+	}
+
+	state, _ := request("GET", "/", "")
+	if state.Mode != WarnMode|ErrorMode || state.Files["f.go"] != DebugMode ||
+		!contains(state.TracePoints, "t.go:42") {
+		t.Errorf("unexpected logging state: %+v", state)
+	}
+
+	state, _ = request("PUT", "/output/stderr", "true")
+	if !output.ToStderr() || state.Output == nil || !state.Output.ToStderr {
+		t.Errorf("expected output to be configured to log to standard error, got: %+v", state.Output)
+	}
+
+	request("DELETE", "/files/f.go", "")
+	if _, ok := GetFileLogMode("f.go"); ok {
+		t.Errorf("expected file log mode for f.go to be reset")
+	}
+	state, _ = request("DELETE", "/tracepoints/t.go:42", "")
+	if GetTracePoint("t.go:42") || contains(state.TracePoints, "t.go:42") {
+		t.Errorf("expected tracepoint t.go:42 to be reset")
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	defer SetGlobalLogMode(DefaultMode)
+	defer ResetFileLogMode("f.go")
+	defer ResetTracePoint("t.go:42")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	logger := RegisterFlags(fs)
+
+	args := []string{"-log-mode", "warn|error", "-log-filter", "f.go:debug", "-log-backtrace-at", "t.go:42"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if m := GetGlobalLogMode(); m != WarnMode|ErrorMode {
+		t.Errorf("expected global log mode %s, got %s", WarnMode|ErrorMode, m)
+	}
+	if m, ok := GetFileLogMode("f.go"); !ok || m != DebugMode {
+		t.Errorf("expected file log mode %s for f.go, got %s", DebugMode, m)
+	}
+	if !GetTracePoint("t.go:42") {
+		t.Errorf("expected tracepoint t.go:42 to be enabled")
+	}
+	if output, ok := logger.Writer().(*Output); !ok || output.Dir() != "" || output.ToStderr() {
+		t.Errorf("expected logger to write out to an unconfigured *Output, got %v", logger.Writer())
+	}
+
+	for _, args := range [][]string{
+		{"-log-mode", "verbose"},
+		{"-log-filter", "f.go"},
+		{"-log-backtrace-at", "t.go:line"},
+		{"-log-backtrace-at", "tXgo:42"},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		RegisterFlags(fs)
+		if err := fs.Parse(args); err == nil {
+			t.Errorf("expected error parsing %v", args)
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected RegisterFlags to panic when configuring a Writer")
+			}
+		}()
+		RegisterFlags(flag.NewFlagSet("test", flag.ContinueOnError), Writer(ioutil.Discard))
+	}()
+}
+
+func TestConfigureFromEnv(t *testing.T) {
+	defer SetGlobalLogMode(DefaultMode)
+	defer ResetFileLogMode("f.go")
+
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := map[string]string{
+		"TEST_LOG_DIR":    dir,
+		"TEST_LOG_MODE":   "warn|error",
+		"TEST_LOG_FILTER": "f.go:debug",
+		"TEST_LOG_FORMAT": "json",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	if _, err := ConfigureFromEnv("TEST", Writer(ioutil.Discard)); err == nil {
+		t.Errorf("expected error configuring a Writer")
+	}
+	logger, err := ConfigureFromEnv("TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Writer().(*Output).SetDir("")
+	if m := GetGlobalLogMode(); m != WarnMode|ErrorMode {
+		t.Errorf("expected global log mode %s, got %s", WarnMode|ErrorMode, m)
+	}
+	if m, ok := GetFileLogMode("f.go"); !ok || m != DebugMode {
+		t.Errorf("expected file log mode %s for f.go, got %s", DebugMode, m)
+	}
+
+	logger.Warnf("%t %d %s", true, 1, "warnf")
+	files, err := ListLogFiles(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a single log file, got %v (%v)", files, err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry Entry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		t.Fatalf("expected JSON formatted entry, got %s: %s", b, err)
+	}
+	if entry.Mode != WarnMode || entry.File != "log_test.go" || entry.Message != "true 1 warnf" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	os.Setenv("TEST_LOG_FORMAT", "yaml")
+	if _, err := ConfigureFromEnv("TEST"); err == nil {
+		t.Errorf("expected error configuring TEST_LOG_FORMAT=yaml")
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	defer SetGlobalLogMode(DefaultMode)
+	defer ResetFileLogMode("f.go")
+	defer ResetFileLogMode("g.go")
+	defer func(interval time.Duration) { configPollInterval = interval }(configPollInterval)
+	configPollInterval = time.Millisecond
+
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "log.json")
+	config := `{"mode": "warn|error", "files": {"f.go": "debug"}, "format": "json"}`
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := NewOutput(filepath.Join(dir, "logs"), false)
+	defer output.SetDir("")
+	logger := New(Writer(output))
+	stop, err := WatchConfig(path, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	if m := GetGlobalLogMode(); m != WarnMode|ErrorMode {
+		t.Errorf("expected global log mode %s, got %s", WarnMode|ErrorMode, m)
+	}
+	if m, ok := GetFileLogMode("f.go"); !ok || m != DebugMode {
+		t.Errorf("expected file log mode %s for f.go, got %s", DebugMode, m)
+	}
+	if f := logger.getFormat(); f != JSONFormat {
+		t.Errorf("expected format %s, got %s", JSONFormat, f)
+	}
+
+	// Changes made by other means are retained across reloads unless the
+	// config file changes the same settings.
+	SetGlobalLogMode(InfoMode)
+
+	config = `{"mode": "warn|error", "files": {"g.go": "warn"}, "output": {"stderr": true}}`
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); !output.ToStderr(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for config to be reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if m := GetGlobalLogMode(); m != InfoMode {
+		t.Errorf("expected global log mode %s, got %s", InfoMode, m)
+	}
+	if _, ok := GetFileLogMode("f.go"); ok {
+		t.Errorf("expected file log mode for f.go to be reset")
+	}
+	if m, ok := GetFileLogMode("g.go"); !ok || m != WarnMode {
+		t.Errorf("expected file log mode %s for g.go, got %s", WarnMode, m)
+	}
+	if d := output.Dir(); d != filepath.Join(dir, "logs") {
+		t.Errorf("expected output directory to be left as is, got %q", d)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(buffer))
+
+	// XXX(irfansharif): This test depends on the call to caller and the
+	// logging statement below being on consecutive lines.
+	_, line := caller(0)
+	NewStdLogger(logger, WarnMode).Printf("%t %d %s", true, 1, "printf")
+
+	regex := fmt.Sprintf("^W.* log_test.go:%d\\] true 1 printf\n$", line+1)
+	match, err := regexp.Match(regex, buffer.Bytes())
+	if err != nil {
+		t.Error(err)
+	}
+	if !match {
+		t.Errorf("expected pattern: \"%s\", got: %s", regex, buffer.String())
+	}
+	buffer.Reset()
+
+	defer stdlog.SetOutput(os.Stderr)
+	defer stdlog.SetFlags(stdlog.LstdFlags)
+	CopyStandardLogTo(logger, ErrorMode)
+
+	_, line = caller(0)
+	stdlog.Println("println")
+
+	regex = fmt.Sprintf("^E.* log_test.go:%d\\] println\n$", line+1)
+	match, err = regexp.Match(regex, buffer.Bytes())
+	if err != nil {
+		t.Error(err)
+	}
+	if !match {
+		t.Errorf("expected pattern: \"%s\", got: %s", regex, buffer.String())
+	}
+	buffer.Reset()
+
+	// Standard library loggers with their default flags write out headers
+	// that can't be parsed, the entries are to be attributed to an unknown
+	// file nonetheless, regardless of the base path.
+	logger = New(Writer(buffer), SkipBasePath())
+	stdlogger := NewStdLogger(logger, InfoMode)
+	stdlogger.SetFlags(stdlog.LstdFlags)
+	stdlogger.Print("print")
+
+	regex = "^I.* \\[\\?\\?\\?\\]:-1\\] .* print\n$"
+	match, err = regexp.Match(regex, buffer.Bytes())
+	if err != nil {
+		t.Error(err)
+	}
+	if !match {
+		t.Errorf("expected pattern: \"%s\", got: %s", regex, buffer.String())
+	}
+}
+
+func TestEveryN(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(buffer))
+
+	// XXX(irfansharif): This test depends on the exact difference in line
+	// numbers between the call to caller and the logging statement below.
+	_, line := caller(0)
+	for i := 0; i < 7; i++ {
+		logger.EveryN(3).Infof("infof %d", i)
+	}
+
+	var messages []string
+	decoder := NewEntryDecoder(buffer)
+	for {
+		var entry Entry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, entry.Message)
+	}
+
+	tp := fmt.Sprintf("log_test.go:%d", line+2)
+	expected := []string{
+		"infof 0",
+		"suppressed 2 similar messages from " + tp,
+		"infof 3",
+		"suppressed 2 similar messages from " + tp,
+		"infof 6",
+	}
+	if fmt.Sprint(messages) != fmt.Sprint(expected) {
+		t.Errorf("expected %q, got %q", expected, messages)
+	}
+}
+
+func TestEveryReconfigured(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(SynchronizedWriter(buffer)))
+
+	// Derived Loggers pick up the parent's format as it's reconfigured,
+	// concurrently with their use.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			logger.setFormat(TextFormat)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		logger.Every(time.Hour).Info("text")
+	}
+	<-done
+
+	derived := logger.EveryN(1)
+	logger.setFormat(JSONFormat)
+	buffer.Reset()
+	derived.Info("json")
+	if !strings.HasPrefix(buffer.String(), "{") {
+		t.Errorf("expected derived Logger to write out JSON, got: %s", buffer.String())
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(buffer), RateLimit(1e-3, 2))
+	for i := 0; i < 5; i++ {
+		logger.Info("info")
+	}
+	if lines := bytes.Count(buffer.Bytes(), []byte("\n")); lines != 2 {
+		t.Errorf("expected burst of 2 entries, got: %s", buffer.String())
+	}
+
+	buffer.Reset()
+	for i := 0; i < 5; i++ {
+		logger.Every(time.Hour).Info("info")
+	}
+	if lines := bytes.Count(buffer.Bytes(), []byte("\n")); lines != 1 {
+		t.Errorf("expected a single entry, got: %s", buffer.String())
+	}
+
+	// Bursts of less than one entry allow for one nonetheless.
+	buffer.Reset()
+	logger = New(Writer(buffer), RateLimit(1e-3, 0))
+	logger.Info("info")
+	if lines := bytes.Count(buffer.Bytes(), []byte("\n")); lines != 1 {
+		t.Errorf("expected a single entry, got: %s", buffer.String())
+	}
+
+	// Idle call sites are evicted, once their limit would allow for the next
+	// entry regardless.
+	sites := &callSites{m: make(map[callSite]*callSiteState)}
+	now := time.Now()
+	sites.allow(limit{every: time.Hour}, "f.go", 1, now)
+	sites.allow(limit{n: 2}, "f.go", 2, now)
+	now = now.Add(callSiteIdleTimeout)
+	sites.allow(limit{n: 2}, "f.go", 3, now)
+	if len(sites.m) != 2 {
+		t.Errorf("expected 2 call sites to be retained, got %d", len(sites.m))
+	}
+	now = now.Add(time.Hour)
+	sites.allow(limit{n: 2}, "f.go", 3, now)
+	if len(sites.m) != 1 {
+		t.Errorf("expected 1 call site to be retained, got %d", len(sites.m))
+	}
+}
+
+func TestDedup(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(buffer), Dedup(time.Hour))
+	for i := 0; i < 3; i++ {
+		logger.Info("info")
+	}
+	logger.Info("another info")
+
+	var messages []string
+	decoder := NewEntryDecoder(buffer)
+	for {
+		var entry Entry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, entry.Message)
+	}
+
+	expected := []string{"info", "last message repeated 2 times", "another info"}
+	if fmt.Sprint(messages) != fmt.Sprint(expected) {
+		t.Errorf("expected %q, got %q", expected, messages)
+	}
+
+	// Repetitions are flushed after the window elapses.
+	buffer.Reset()
+	logger = New(Writer(buffer), Dedup(time.Millisecond))
+	for i := 0; i < 2; i++ {
+		logger.Info("info")
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	logger.dedup.Lock()
+	defer logger.dedup.Unlock()
+	if !strings.Contains(buffer.String(), "last message repeated 1 times") {
+		t.Errorf("expected repetitions to be flushed, got: %s", buffer.String())
+	}
+}
+
+type safeMessage string
+
+func (s safeMessage) SafeMessage() string { return string(s) }
+
+type safeCount int
+
+func (c safeCount) SafeMessage() string { return fmt.Sprintf("count-%d", int(c)) }
+
+func TestRedactableOutput(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(buffer), RedactableOutput())
+	logger.Infof("%d ranges in %s, %s for %q", Safe(42), "/Table/51/1", safeMessage("rebalancing"), "user‹›")
+
+	expected := "42 ranges in ‹/Table/51/1›, rebalancing for ‹\"user??\"›"
+	if !strings.HasSuffix(buffer.String(), "] "+expected+"\n") {
+		t.Errorf("expected message %q, got: %s", expected, buffer.String())
+	}
+	if redacted := Redact(expected); redacted != "42 ranges in ‹×›, rebalancing for ‹×›" {
+		t.Errorf("unexpected redacted message %q", redacted)
+	}
+
+	// SafeMessagers are formatted using SafeMessage only for %s and %v.
+	buffer.Reset()
+	logger.Infof("%d %x %s|%9s|%v", safeCount(42), safeCount(255), safeCount(7), safeCount(7), (*safeCount)(nil))
+	expected = "42 ff count-7|  count-7|<nil>"
+	if !strings.HasSuffix(buffer.String(), "] "+expected+"\n") {
+		t.Errorf("expected message %q, got: %s", expected, buffer.String())
+	}
+}
+
+func TestFlightRecorder(t *testing.T) {
+	SetGlobalLogMode(WarnMode)
+	defer SetGlobalLogMode(DefaultMode)
+
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(buffer), FlightRecorder(3))
+	for i := 0; i < 4; i++ {
+		logger.Debugf("debugf %d", i)
+	}
+	logger.Warn("warn")
+	logger.Fatal("fatal")
+
+	var messages []string
+	decoder := NewEntryDecoder(buffer)
+	for {
+		var entry Entry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, entry.Message)
+	}
+	expected := []string{"warn", "debugf 2", "debugf 3", "fatal"}
+	if fmt.Sprint(messages) != fmt.Sprint(expected) {
+		t.Errorf("expected %q, got %q", expected, messages)
+	}
+
+	logger.Debug("debug")
+	server := httptest.NewServer(AdminHandler(AdminFlightRecorder(logger)))
+	defer server.Close()
+	resp, err := http.Post(server.URL+"/recorder", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var state AdminState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Recorder == nil || state.Recorder.Dumped != 1 || state.Recorder.Pending != 0 {
+		t.Errorf("expected a single entry to be dumped, got: %+v", state.Recorder)
+	}
+	if !strings.HasSuffix(buffer.String(), "] debug\n") {
+		t.Errorf("expected debug entry to be dumped, got: %s", buffer.String())
+	}
+
+	// Non-positive sizes disable the flight recorder.
+	for _, n := range []int{0, -1} {
+		logger := New(Writer(ioutil.Discard), FlightRecorder(n))
+		logger.Debug("debug")
+		if logger.recorder != nil || logger.DumpFlightRecorder() != 0 {
+			t.Errorf("expected flight recorder of size %d to be disabled", n)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	logger := New(Writer(buffer), WriterName("stats-test"), Dedup(time.Hour))
+	before := Stats()
+	for i := 0; i < 3; i++ {
+		logger.Error("error")
+	}
+
+	// The statistics are process-global, assert on the differences.
+	after := Stats()
+	bw, aw := before.Writers["stats-test"], after.Writers["stats-test"]
+	if aw.Lines-bw.Lines != 1 || aw.Dropped-bw.Dropped != 2 || aw.Bytes-bw.Bytes != int64(buffer.Len()) {
+		t.Errorf("unexpected writer stats, before: %+v, after: %+v", bw, aw)
+	}
+	b, a := before.Modes[ErrorMode], after.Modes[ErrorMode]
+	if a.Lines-b.Lines != 1 || a.Dropped-b.Dropped != 2 {
+		t.Errorf("unexpected mode stats, before: %+v, after: %+v", b, a)
+	}
+
+	// Unnamed writers aren't tracked individually.
+	New(Writer(ioutil.Discard)).Error("error")
+	if n := len(Stats().Writers); n != len(after.Writers) {
+		t.Errorf("expected unnamed writer to not be tracked, got %d writers (from %d)", n, len(after.Writers))
+	}
+}
+
+func TestIntercept(t *testing.T) {
+	var intercepted, hooked []Entry
+	unregister := Intercept(func(e Entry) {
+		intercepted = append(intercepted, e)
+	})
+	logger := New(Writer(ioutil.Discard), Hook(func(e Entry) {
+		hooked = append(hooked, e)
+	}))
+
+	// XXX(irfansharif): This test depends on the exact difference in line
+	// numbers between the call to caller and the logging statement below.
+	_, line := caller(0)
+	logger.Errorf("errorf %d", 42)
+	logger.Debug("debug") // Filtered out.
+	unregister()
+	logger.Warn("warn")
+
+	expected := Entry{Mode: ErrorMode, File: "log_test.go", Line: line + 1, Message: "errorf 42"}
+	if len(intercepted) != 1 {
+		t.Fatalf("expected a single intercepted entry, got: %v", intercepted)
+	}
+	if e := intercepted[0]; e.Mode != expected.Mode || e.File != expected.File || e.Line != expected.Line || e.Message != expected.Message {
+		t.Errorf("expected %+v, got %+v", expected, e)
+	}
+	if len(hooked) != 2 || hooked[1].Message != "warn" {
+		t.Errorf("expected entries to be hooked, got: %v", hooked)
+	}
+}
+
+func TestSyslogWriter(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	writer, err := NewSyslogWriter("udp", udp.LocalAddr().String(), SyslogFacility(16), SyslogTag("logger"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	New(Writer(writer)).Warn("warn")
+
+	b := make([]byte, 1024)
+	n, _, err := udp.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regex := fmt.Sprintf(`^<132>1 \S+Z %s logger %d - - log_test.go:\d+\] warn$`, regexp.QuoteMeta(hostname), pid)
+	if match, _ := regexp.Match(regex, b[:n]); !match {
+		t.Errorf("expected pattern: %q, got: %q", regex, b[:n])
+	}
+
+	// Tracepoint backtraces are sent as part of the entry they precede.
+	_, line := caller(0)
+	tp := fmt.Sprintf("log_test.go:%d", line+3)
+	SetTracePoint(tp)
+	New(Writer(writer)).Warn("traced")
+	ResetTracePoint(tp)
+
+	b = make([]byte, 1<<16)
+	if n, _, err = udp.ReadFrom(b); err != nil {
+		t.Fatal(err)
+	}
+	regex = fmt.Sprintf(`(?s)^<132>1 \S+Z %s logger %d - - %s\] traced\ngoroutine \d+ \[running\]:\n.+$`,
+		regexp.QuoteMeta(hostname), pid, regexp.QuoteMeta(tp))
+	if match, _ := regexp.Match(regex, b[:n]); !match {
+		t.Errorf("expected pattern: %q, got: %q", regex, b[:n])
+	}
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+
+	writer, err = NewSyslogWriter("tcp", tcp.Addr().String(), SyslogRFC3164())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	New(Writer(writer)).Error("error")
+
+	conn, err := tcp.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	length, err := reader.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ = strconv.Atoi(strings.TrimSpace(length))
+	b = make([]byte, n)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		t.Fatal(err)
+	}
+	regex = fmt.Sprintf(`^<11>\w{3} [ \d]\d \d{2}:\d{2}:\d{2} %s %s\[%d\]: log_test.go:\d+\] error$`,
+		regexp.QuoteMeta(hostname), regexp.QuoteMeta(program), pid)
+	if match, _ := regexp.Match(regex, b); !match {
+		t.Errorf("expected pattern: %q, got: %q", regex, b)
+	}
+
+	// Entries are newline-terminated over unix stream connections.
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	unix, err := net.Listen("unix", filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close()
+
+	writer, err = NewSyslogWriter("unix", unix.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	New(Writer(writer)).Info("info")
+	New(Writer(writer)).Info("multi\nline")
+
+	conn, err = unix.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	lines := bufio.NewReader(conn)
+	for _, msg := range []string{"info", `multi\\nline`} {
+		framed, err := lines.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		regex = fmt.Sprintf(`^<14>1 \S+ %s %s %d - - log_test.go:\d+\] %s\n$`,
+			regexp.QuoteMeta(hostname), regexp.QuoteMeta(program), pid, msg)
+		if match, _ := regexp.MatchString(regex, framed); !match {
+			t.Errorf("expected pattern: %q, got: %q", regex, framed)
+		}
+	}
+}
+
+func TestStreamWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Reserve an address, with the collector initially unreachable.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	writer := NewStreamWriter(addr, dir)
+	defer writer.Close()
+	logger := New(Writer(writer))
+	logger.Info("spooled")
+
+	if files, err := ListLogFiles(dir); err != nil || len(files) != 1 {
+		t.Fatalf("expected a single spooled file, got: %v (err: %v)", files, err)
+	}
+
+	listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for _, expected := range []string{"spooled", "streamed"} {
+		if expected == "streamed" {
+			logger.Info("streamed")
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(line, "] "+expected+"\n") {
+			t.Errorf("expected %s entry, got: %s", expected, line)
+		}
+	}
+	if files, err := ListLogFiles(dir); err != nil || len(files) != 0 {
+		t.Errorf("expected spooled files to be removed, got: %v (err: %v)", files, err)
+	}
+}
+
+func TestStreamWriterStalled(t *testing.T) {
+	defer func(timeout time.Duration) { streamWriteTimeout = timeout }(streamWriteTimeout)
+	streamWriteTimeout = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The collector accepts connections but never reads from them.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	connected := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			connected <- conn
+		}
+	}()
+
+	writer := NewStreamWriter(listener.Addr().String(), dir)
+	defer writer.Close()
+	select {
+	case conn := <-connected:
+		defer conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected writer to connect to the collector")
+	}
+	for {
+		writer.mu.Lock()
+		conn := writer.conn
+		writer.mu.Unlock()
+		if conn != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Once the connection's buffers fill up, entries are spooled instead of
+	// blocking indefinitely.
+	logger := New(Writer(writer))
+	message := strings.Repeat("x", 64<<10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1024; i++ {
+			logger.Info(message)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("expected writes to a stalled collector to time out")
+	}
+}
+
+func TestColorize(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	New(Writer(buffer)).Error("error")
+	if strings.Contains(buffer.String(), "\x1b[") {
+		t.Errorf("expected no colors when not writing out to a terminal, got: %q", buffer.String())
+	}
+
+	// Whether to colorize is resolved once, barring Outputs.
+	if l := New(Writer(buffer)); l.color != colorNever {
+		t.Errorf("expected colorizing to be resolved, got: %v", l.color)
+	}
+	output := NewOutput("", false)
+	if l := New(Writer(SynchronizedWriter(output))); l.colorOutput != output || l.colorized() {
+		t.Errorf("expected colorizing to be determined by the Output")
+	}
+
+	buffer.Reset()
+	New(Writer(buffer), Colorize(true)).Warn("warn")
+	regex := `^\x1b\[33mW\x1b\[0m\d{6} [\d:.]+ \x1b\[33mlog_test.go:\d+\x1b\[0m\] warn`
+	if match, _ := regexp.Match(regex, buffer.Bytes()); !match {
+		t.Errorf("expected pattern: %q, got: %q", regex, buffer.String())
+	}
+}
+
+func TestEscapeMessages(t *testing.T) {
+	forged := "user input\nE180419 06:33:04.606396 forged.go:42] \x1b[31mforged\r\xff"
+	for _, tc := range []struct {
+		multiLine MultiLine
+		expected  string
+	}{
+		{MultiLineIndent, "user input\n\tE180419 06:33:04.606396 forged.go:42] \\x1b[31mforged\\r�"},
+		{MultiLineEscape, "user input\\nE180419 06:33:04.606396 forged.go:42] \\x1b[31mforged\\r�"},
+	} {
+		buffer := new(bytes.Buffer)
+		New(Writer(buffer), EscapeMessages(tc.multiLine)).Info(forged)
+
+		var entries []Entry
+		decoder := NewEntryDecoder(buffer)
+		for {
+			var entry Entry
+			if err := decoder.Decode(&entry); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) != 1 || entries[0].Message != tc.expected {
+			t.Errorf("expected a single entry with message %q, got: %+v", tc.expected, entries)
+		}
+	}
+}
+
+// countingStringer counts the number of times it's formatted.
+type countingStringer struct{ count *int }
+
+func (c countingStringer) String() string {
+	*c.count++
+	return "formatted"
+}
+
+func TestLazyFormatting(t *testing.T) {
+	SetGlobalLogMode(InfoMode)
+	defer SetGlobalLogMode(DefaultMode)
+
+	var count int
+	logger := New(Writer(ioutil.Discard))
+	logger.Debugf("%s", countingStringer{&count})
+	logger.Debug(countingStringer{&count})
+	if count != 0 {
+		t.Errorf("expected filtered out statements to not be formatted, formatted %d times", count)
+	}
+	logger.Infof("%s", countingStringer{&count})
+	if count != 1 {
+		t.Errorf("expected statement to be formatted once, formatted %d times", count)
+	}
+
+	if logger.DebugEnabled() || !logger.Enabled(InfoMode) {
+		t.Errorf("expected only info entries to be enabled")
+	}
+	SetFileLogMode("log_test.go", DebugMode)
+	defer ResetFileLogMode("log_test.go")
+	if !logger.DebugEnabled() || logger.Enabled(InfoMode) {
+		t.Errorf("expected only debug entries to be enabled for log_test.go")
+	}
+}
+
+func TestCallSiteCaching(t *testing.T) {
+	SetGlobalLogMode(InfoMode)
+	defer SetGlobalLogMode(DefaultMode)
+
+	buf := new(bytes.Buffer)
+	logger := New(Writer(buf), Flags(Lshortfile))
+	_, line := caller(0)
+	for i := 0; i < 2; i++ {
+		logger.Debug("filtered out")
+		logger.Info("first")
+		logger.Info("second")
+	}
+	expected := fmt.Sprintf(" log_test.go:%d] first\n log_test.go:%d] second\n", line+3, line+4)
+	if got := buf.String(); got != strings.Repeat(expected, 2) {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Repeat(expected, 2), got)
+	}
+
+	// Cached decisions are to be invalidated as the file log mode changes.
+	buf.Reset()
+	SetFileLogMode("log_test.go", DebugMode)
+	defer ResetFileLogMode("log_test.go")
+	for i := 0; i < 2; i++ {
+		logger.Debug("filtered in")
+		logger.Info("filtered out")
+	}
+	expected = fmt.Sprintf(" log_test.go:%d] filtered in\n", line+16)
+	if got := buf.String(); got != strings.Repeat(expected, 2) {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Repeat(expected, 2), got)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		logger.Info("filtered out")
+	}); allocs != 0 {
+		t.Errorf("expected filtered out statements to not allocate, got %v allocs", allocs)
+	}
+}
+
+func TestEmitAllocations(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := New(Writer(buf), Flags(LstdFlags|LUTC))
+	now := time.Date(2018, time.April, 19, 6, 33, 4, 606396000, time.UTC)
+	logger.emit(InfoMode, now, "fname.go", 42, "message", nil)
+	if expected := "I180419 06:33:04.606396 fname.go:42] message\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	logger = New(Writer(ioutil.Discard), Flags(LstdFlags|LUTC))
+	if allocs := testing.AllocsPerRun(100, func() {
+		logger.emit(InfoMode, now, "fname.go", 42, "message", nil)
+	}); allocs != 0 {
+		t.Errorf("expected text entries to be encoded without allocating, got %v allocs", allocs)
+	}
+
+	// Backtraces are written out as part of the same write as their entry.
+	var writes []string
+	logger = New(Writer(writerFunc(func(b []byte) (int, error) {
+		writes = append(writes, string(b))
+		return len(b), nil
+	})), Flags(LstdFlags|LUTC))
+	logger.emit(InfoMode, now, "fname.go", 42, "message", []byte("goroutine 1 [running]:\n"))
+	if expected := "goroutine 1 [running]:\nI180419 06:33:04.606396 fname.go:42] message\n"; len(writes) != 1 || writes[0] != expected {
+		t.Errorf("expected a single write %q, got %q", expected, writes)
+	}
+}
+
+type writerFunc func(b []byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) { return f(b) }
+
+func BenchmarkInfo(b *testing.B) {
+	logger := New(Writer(ioutil.Discard))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("message")
+	}
+}
+
+func BenchmarkInfof(b *testing.B) {
+	logger := New(Writer(ioutil.Discard))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infof("message %d %s", 42, "arg")
+	}
+}
+
+func BenchmarkInfoParallel(b *testing.B) {
+	logger := New(Writer(ioutil.Discard))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			logger.Info("message")
+		}
+	})
+}
+
+func BenchmarkInfoJSON(b *testing.B) {
+	logger := New(Writer(ioutil.Discard), OutputFormat(JSONFormat))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("message")
+	}
+}
+
+func BenchmarkDebugFilteredOut(b *testing.B) {
+	logger := New(Writer(ioutil.Discard))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Debugf("message %d", 42)
+	}
+}
+
+func BenchmarkEmit(b *testing.B) {
+	logger := New(Writer(ioutil.Discard))
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.emit(InfoMode, now, "fname.go", 42, "message", nil)
+	}
+}
@@ -25,15 +25,16 @@ package log
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
-	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
-// TODO(irfansharif): Implement an _ import like debug/pprof for logger config?
 // TODO(irfansharif): Implement a catchall global logger with warning?
 // TODO(irfansharif): Implement tagging API?
 // TODO(irfansharif): Implement custom leveling/verbosity with filtering?
@@ -44,6 +45,27 @@ type Logger struct {
 	w        io.Writer // Where logs are written to
 	flag     Flag      // Flag set determining log headers. See options.go
 	basePath string    // Base path of the consumer's repository, optional
+	format   *int32    // Format log entries are encoded in, accessed atomically. Shared with derived Loggers, see Every
+
+	limit limit       // Per call site rate limit, if any. See ratelimit.go
+	sites *callSites  // Per call site rate limiting state, shared across derived Loggers
+	dedup *dedupState // Deduplication state, if configured. See dedup.go
+
+	redactable bool   // Whether arguments are marked for redaction. See redact.go
+	helper     func() // Marks the calling function as a test helper. See Writer
+
+	recorder *flightRecorder // Recently logged entries, if configured. See recorder.go
+
+	writerName string       // Name identifying the writer in output statistics. See stats.go
+	wstats     *outputStats // Output statistics for the writer
+
+	hooks []func(Entry) // Invoked for every entry that isn't filtered out. See hooks.go
+
+	color       colorMode // Whether text log headers are colorized, resolved in New. See color.go
+	colorOutput *Output   // Output whose destination determines whether they are, if any
+
+	escape    bool      // Whether messages are escaped. See escape.go
+	multiLine MultiLine // How multi-line messages are escaped, if they are
 }
 
 // configure sets up the default options for the Logger, these include a
@@ -57,6 +79,19 @@ func configure(l *Logger) {
 	l.w = DefaultWriter()
 	l.flag = LstdFlags
 	l.basePath = ""
+	l.format = new(int32)
+	l.setFormat(TextFormat)
+	l.limit = limit{}
+	l.sites = &callSites{m: make(map[callSite]*callSiteState)}
+	l.dedup = nil
+	l.redactable = false
+	l.helper = func() {}
+	l.recorder = nil
+	l.writerName = ""
+	l.hooks = nil
+	l.color = colorAuto
+	l.escape = false
+	l.multiLine = MultiLineVerbatim
 }
 
 // New returns a new Logger, configured with the provided options, if any.
@@ -68,43 +103,68 @@ func New(options ...option) *Logger {
 	for _, option := range options {
 		option(l)
 	}
+	l.registerWriterStats()
+	l.resolveColor()
 	return l
 }
 
+// Writer returns the io.Writer the Logger writes out to.
+func (l *Logger) Writer() io.Writer {
+	return l.w
+}
+
+// getFormat returns the format log entries are encoded in.
+func (l *Logger) getFormat() Format {
+	return Format(atomic.LoadInt32(l.format))
+}
+
+// setFormat sets the format log entries are encoded in, by the Logger and
+// those derived from it (see Every). It's safe to call concurrently with the
+// Logger being used, for it to be reconfigured at runtime (see WatchConfig).
+func (l *Logger) setFormat(f Format) {
+	atomic.StoreInt32(l.format, int32(f))
+}
+
 // Info logs to the INFO log. Arguments are handled in the manner of fmt.Println;
 // a newline is appended at the end.
 func (l *Logger) Info(v ...interface{}) {
-	l.log(InfoMode, fmt.Sprintln(v...))
+	l.helper()
+	l.log(InfoMode, "", v, true)
 }
 
 // Infof logs to the INFO log. Arguments are handled in the manner of fmt.Printf;
 // a newline is appended at the end.
 func (l *Logger) Infof(format string, v ...interface{}) {
-	l.log(InfoMode, fmt.Sprintf(format, v...))
+	l.helper()
+	l.log(InfoMode, format, v, false)
 }
 
 // Warn logs to the WARN log. Arguments are handled in the manner of fmt.Println;
 // a newline is appended at the end.
 func (l *Logger) Warn(v ...interface{}) {
-	l.log(WarnMode, fmt.Sprintln(v...))
+	l.helper()
+	l.log(WarnMode, "", v, true)
 }
 
 // Warnf logs to the WARN log. Arguments are handled in the manner of fmt.Printf;
 // a newline is appended at the end.
 func (l *Logger) Warnf(format string, v ...interface{}) {
-	l.log(WarnMode, fmt.Sprintf(format, v...))
+	l.helper()
+	l.log(WarnMode, format, v, false)
 }
 
 // Error logs to the ERROR log. Arguments are handled in the manner of fmt.Println;
 // a newline is appended at the end.
 func (l *Logger) Error(v ...interface{}) {
-	l.log(ErrorMode, fmt.Sprintln(v...))
+	l.helper()
+	l.log(ErrorMode, "", v, true)
 }
 
 // Errorf logs to the ERROR log. Arguments are handled in the manner of fmt.Printf;
 // a newline is appended at the end.
 func (l *Logger) Errorf(format string, v ...interface{}) {
-	l.log(ErrorMode, fmt.Sprintf(format, v...))
+	l.helper()
+	l.log(ErrorMode, format, v, false)
 }
 
 // Fatal logs to the FATAL log. Arguments are handled in the manner of fmt.Println;
@@ -113,7 +173,8 @@ func (l *Logger) Errorf(format string, v ...interface{}) {
 // TODO(irfansharif): Including a stack trace of all running goroutines, then
 // calls os.Exit(255).
 func (l *Logger) Fatal(v ...interface{}) {
-	l.log(FatalMode, fmt.Sprintln(v...))
+	l.helper()
+	l.log(FatalMode, "", v, true)
 }
 
 // Fatalf logs to the FATAL log. Arguments are handled in the manner of fmt.Printf;
@@ -122,78 +183,269 @@ func (l *Logger) Fatal(v ...interface{}) {
 // TODO(irfansharif): Including a stack trace of all running goroutines, then
 // calls os.Exit(255).
 func (l *Logger) Fatalf(format string, v ...interface{}) {
-	l.log(FatalMode, fmt.Sprintf(format, v...))
+	l.helper()
+	l.log(FatalMode, format, v, false)
 }
 
 // Debug logs to the DEBUG log. Arguments are handled in the manner of fmt.Println;
 // a newline is appended at the end.
 func (l *Logger) Debug(v ...interface{}) {
-	l.log(DebugMode, fmt.Sprintln(v...))
+	l.helper()
+	l.log(DebugMode, "", v, true)
 }
 
 // Debugf logs to the DEBUG log. Arguments are handled in the manner of fmt.Printf;
 // a newline is appended at the end.
 func (l *Logger) Debugf(format string, v ...interface{}) {
-	l.log(DebugMode, fmt.Sprintf(format, v...))
+	l.helper()
+	l.log(DebugMode, format, v, false)
+}
+
+// Enabled returns whether entries of the provided mode logged by the caller
+// are to be written out, as per the global log mode and the file log mode
+// for the caller's file, if any. It allows for logging statements that are
+// expensive to evaluate to be guarded:
+//
+//	if logger.Enabled(log.DebugMode) {
+//	    logger.Debugf("state: %s", expensiveDump())
+//	}
+//
+// Statements guarded so aren't retained by the Logger's flight recorder, if
+// any, when disabled (see FlightRecorder).
+func (l *Logger) Enabled(m Mode) bool {
+	if filteredOut(m) {
+		return false
+	}
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:]) // Skip runtime.Callers, and Logger.Enabled itself.
+	return resolveSite(pcs[0], m).enabled
+}
+
+// DebugEnabled returns whether debug entries logged by the caller are to be
+// written out, see Enabled.
+func (l *Logger) DebugEnabled() bool {
+	if filteredOut(DebugMode) {
+		return false
+	}
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:]) // Skip runtime.Callers, and Logger.DebugEnabled itself.
+	return resolveSite(pcs[0], DebugMode).enabled
 }
 
 // Logger.log is only to be called from
-// Logger.{Info,Warn,Error,Fatal,Debug}{,f}. We use a depth of two to
-// retrieve the caller immediately preceding it.
-func (l *Logger) log(lmode Mode, data string) {
-	// TODO(irfansharif): Right now this isn't robust to shared filenames
-	// across varied sub packages (for tracepoints and file log modes both).
-	// This is a stand-in to allow for direct file name specification without
-	// fully-specified paths (in the host machine or relative to project root).
-	// We could implement for project root relative paths if project root was
-	// provided.
-	file, line := caller(2)
-	bfile := filepath.Base(file)
-	tp := fmt.Sprintf("%s:%d", bfile, line)
-
-	tpenabled := GetTracePoint(tp)
-	if tpenabled {
-		// Skip logger.log, and the invoking public wrapper
-		// Logger.{Info,Warn,Error,Fatal,Debug}{,f}
-		l.w.Write(stacktrace(2))
+// Logger.{Info,Warn,Error,Fatal,Debug}{,f}. We skip three stack frames to
+// retrieve the caller immediately preceding it, whose filtering and
+// tracepoint decisions are cached (see resolveSite). The arguments are
+// formatted in the manner of fmt.Println if ln is specified, fmt.Printf
+// otherwise, and only if the entry isn't filtered out.
+func (l *Logger) log(lmode Mode, format string, v []interface{}, ln bool) {
+	l.helper()
+	if l.recorder == nil && filteredOut(lmode) {
+		return // Filtered out, no matter the call site.
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // Skip runtime.Callers, logger.log, and the public wrapper.
+	site := resolveSite(pcs[0], lmode)
+
+	// Entries filtered out are nonetheless formatted if they're to be
+	// recorded (see FlightRecorder), or if they have a tracepoint enabled (so
+	// that the backtrace is emitted).
+	if l.recorder == nil && !site.enabled && !site.tpenabled {
+		return
 	}
 
-	var shouldLog bool
-	if fmode, ok := GetFileLogMode(bfile); ok && (fmode&lmode) != DisabledMode {
+	var data string
+	if ln {
+		data = l.sprint(v...)
+	} else {
+		data = l.sprintf(format, v...)
+	}
+
+	// Skip logger.log, and the invoking public wrapper
+	// Logger.{Info,Warn,Error,Fatal,Debug}{,f}
+	l.outputSite(site, 2, data)
+}
+
+// shouldLog returns whether entries of the provided mode logged from the
+// provided file (fully qualified) are to be written out, as per the global and
+// file log modes.
+func shouldLog(lmode Mode, file string) bool {
+	fmode, ok := fileLogMode(file)
+	if ok && (fmode&lmode) != DisabledMode {
 		// Log mode satisfies the specific file mode. Since file mode filtering
 		// is only used for overrides, we check for this first.
 		// Log mode satisfies specific file mode, and crucially, not the global
 		// mode. File mode filtering is only to be used for overrides, if global
 		// log mode is satisfied, we already capture it.
-		shouldLog = true
-	} else if gmode := GetGlobalLogMode(); !ok && (gmode&lmode) != DisabledMode {
+		return true
+	}
+	if gmode := GetGlobalLogMode(); !ok && (gmode&lmode) != DisabledMode {
 		// Log mode satisfies global mode, and crucially, there isn't
 		// a file specific override.
-		shouldLog = true
-	} else if (lmode & FatalMode) != DisabledMode {
-		// Logger.Fatal{,f} statements aren't filtered out.
-		shouldLog = true
+		return true
+	}
+	// Logger.Fatal{,f} statements aren't filtered out.
+	return (lmode & FatalMode) != DisabledMode
+}
+
+// Logger.output writes out the log entry for the logging statement at the
+// provided file (fully qualified) and line, if not filtered out, emitting a
+// backtrace if the corresponding tracepoint is enabled. The backtrace skips
+// the specified number of stack frames preceding Logger.output, typically to
+// omit the logging library's own.
+func (l *Logger) output(lmode Mode, file string, line int, skip int, data string) {
+	l.helper()
+	// +1 to skip logger.output itself.
+	l.outputSite(newSiteDecision(lmode, file, line), skip+1, data)
+}
+
+// Logger.outputSite is Logger.output for the logging statement with the
+// provided (possibly cached) call site decision.
+func (l *Logger) outputSite(site *siteDecision, skip int, data string) {
+	l.helper()
+
+	lmode, file, line, tp := site.lmode, site.file, site.line, site.tp
+	var stack []byte
+	if site.tpenabled {
+		// +1 to skip logger.outputSite itself.
+		stack = stacktrace(skip + 1)
 	}
 
-	if !shouldLog {
+	enabled := site.enabled
+	now := time.Now()
+	if enabled && l.limit != (limit{}) {
+		allowed, suppressed := l.sites.allow(l.limit, file, line, now)
+		if !allowed {
+			enabled = false
+			l.recordDrop(lmode)
+		} else if suppressed > 0 {
+			l.emit(lmode, now, file, line,
+				fmt.Sprintf("suppressed %d similar messages from %s", suppressed, tp), nil)
+		}
+	}
+
+	if l.recorder != nil {
+		// Entries are recorded regardless of whether they're filtered out.
+		// Fatal ones are preceded by those retained that weren't written
+		// out already.
+		if enabled && (lmode&FatalMode) != DisabledMode {
+			l.DumpFlightRecorder()
+		}
+		l.recorder.record(recordedEntry{
+			mode: lmode, t: now, file: file, line: line, data: data, emitted: enabled,
+		})
+	}
+
+	if !enabled {
+		if stack != nil {
+			// The backtrace is emitted regardless.
+			l.emitStack(now, file, line, stack)
+		}
+		return
+	}
+	l.intercept(lmode, now, file, line, data)
+	if l.dedup != nil {
+		l.dedup.emit(l, lmode, now, file, line, data, stack)
 		return
 	}
+	l.emit(lmode, now, file, line, data, stack)
+}
+
+// Logger.emit encodes the log entry as per the configured format, writing it
+// out in a single write. Entries are encoded into pooled buffers, so that
+// (barring JSONFormat) doing so doesn't allocate. The backtrace, if any, is
+// written out preceding the entry as part of the same write, or as part of
+// the entry with JSONFormat (see Entry.Stack).
+//
+// Logging through the public API still allocates (once per entry for
+// Logger.Info and Logger.Infof, see BenchmarkInfo), ahead of emit: the
+// message is formatted into a string using fmt.Sprint or fmt.Sprintf, as it's
+// also handed to hooks and retained by the flight recorder and Dedup.
+func (l *Logger) emit(lmode Mode, t time.Time, file string, line int, data string, stack []byte) {
+	l.helper()
+
+	data = l.escapeMessage(data)
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	b := *buf
+	if l.getFormat() == JSONFormat {
+		if l.flag&LUTC != 0 {
+			t = t.UTC()
+		}
+		m, _ := json.Marshal(Entry{
+			Mode:    lmode,
+			Time:    t,
+			File:    l.fileName(file),
+			Line:    line,
+			Message: strings.TrimSuffix(data, "\n"),
+			Stack:   string(stack),
+		})
+		b = append(b, m...)
+		b = append(b, '\n')
+	} else {
+		b = append(b, stack...)
+		var color string
+		if l.colorized() {
+			color = modeColor(lmode)
+		}
+		b = l.appendHeader(b, lmode, t, file, line, color)
+		b = append(b, data...)
+		if len(data) == 0 || data[len(data)-1] != '\n' {
+			// Logger.{Info,Warn,Error,Fatal,Debug}f don't include a trailing
+			// newline, but log entries are newline delimited.
+			b = append(b, '\n')
+		}
+	}
+	*buf = b
+
+	var n int
+	var err error
+	if w, ok := l.w.(entryWriter); ok {
+		n, err = w.writeEntry(b, file)
+	} else {
+		n, err = l.w.Write(b)
+	}
+	l.recordWrite(lmode, n, err)
+}
 
-	var buf bytes.Buffer
-	buf.Write(l.header(lmode, time.Now(), file, line))
-	buf.WriteString(data)
-	l.w.Write(buf.Bytes())
+// Logger.emitStack writes out the backtrace for a logging statement whose
+// entry isn't, as is with the text format, or as an entry of its own with
+// JSONFormat (one with only the time stamp, file name, line number and
+// backtrace set).
+func (l *Logger) emitStack(t time.Time, file string, line int, stack []byte) {
+	l.helper()
+
+	if l.getFormat() != JSONFormat {
+		l.w.Write(stack)
+		return
+	}
+	if l.flag&LUTC != 0 {
+		t = t.UTC()
+	}
+	m, _ := json.Marshal(Entry{
+		Time:  t,
+		File:  l.fileName(file),
+		Line:  line,
+		Stack: string(stack),
+	})
+	l.w.Write(append(m, '\n'))
 }
 
-// header, given the local log mode, time stamp, file name (fully qualified)
-// and line number, formats the log header as per Logger.flag and returns the
-// corresponding byte array. It also factors in the configured base path, if
-// any, so that of Llongfile is specified, the base path prefix is truncated.
-func (l *Logger) header(lmode Mode, t time.Time, file string, line int) []byte {
-	var b []byte
-	var buf *[]byte = &b
+// appendHeader, given the local log mode, time stamp, file name (fully
+// qualified) and line number, formats the log header as per Logger.flag and
+// appends it to the provided byte slice. It also factors in the configured
+// base path, if any, so that of Llongfile is specified, the base path prefix
+// is truncated. The mode and file name components are colorized using the
+// provided ANSI escape sequence, if any (see Colorize).
+func (l *Logger) appendHeader(b []byte, lmode Mode, t time.Time, file string, line int, color string) []byte {
 	if l.flag&(Lmode) != 0 {
-		*buf = append(*buf, lmode.byte())
+		b = append(b, color...)
+		b = append(b, lmode.byte())
+		if color != "" {
+			b = append(b, colorReset...)
+		}
 	}
 	if l.flag&LUTC != 0 {
 		t = t.UTC()
@@ -201,82 +453,69 @@ func (l *Logger) header(lmode Mode, t time.Time, file string, line int) []byte {
 	if l.flag&(Ldate|Ltime|Lmicroseconds) != 0 {
 		datef := l.flag&Ldate != 0
 		timef := l.flag&(Ltime|Lmicroseconds) != 0
+		prefix := getDatePrefix(t)
 		if datef {
-			year, month, day := t.Date()
-			if year < 2000 {
-				year = 2000
-			}
-			itoa(buf, year-2000, 2)
-			itoa(buf, int(month), 2)
-			itoa(buf, day, 2)
+			b = append(b, prefix.date()...)
 		}
 
 		if datef && timef {
-			*buf = append(*buf, ' ')
+			b = append(b, ' ')
 		}
 
 		if timef {
-			hour, min, sec := t.Clock()
-			itoa(buf, hour, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, min, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, sec, 2)
+			b = append(b, prefix.clock()...)
 			if l.flag&Lmicroseconds != 0 {
-				*buf = append(*buf, '.')
-				itoa(buf, t.Nanosecond()/1e3, 6)
+				b = append(b, '.')
+				b = appendInt(b, t.Nanosecond()/1e3, 6)
 			}
 		}
 	}
 
-	*buf = append(*buf, ' ')
+	b = append(b, ' ')
 
 	if l.flag&(Lshortfile|Llongfile) != 0 {
-		// This will panic with index out of range if the project path is
-		// improperly configured. Consider project path is defined to be
-		// [...]/app/pkg/subpkg (read: not the project root), and is used at
-		// the top level, [...]/app/main.go, it will panic is it's trying to
-		// drop the prefix [...]/app.
-		file = file[len(l.basePath):]
-		if len(l.basePath) != 0 {
-			// [1:] is for leading '/', if basePath is non-empty.
-			file = file[1:]
+		b = append(b, color...)
+		b = append(b, l.fileName(file)...)
+		b = append(b, ':')
+		b = appendInt(b, line, -1)
+		if color != "" {
+			b = append(b, colorReset...)
 		}
-
-		if l.flag&Lshortfile != 0 {
-			short := file
-			for i := len(file) - 1; i > 0; i-- {
-				if file[i] == '/' {
-					short = file[i+1:]
-					break
-				}
-			}
-			file = short
-		}
-		*buf = append(*buf, file...)
-		*buf = append(*buf, ':')
-		itoa(buf, line, -1)
-		*buf = append(*buf, "] "...)
+		b = append(b, "] "...)
 	}
 	return b
 }
 
-// Cheap integer to fixed-width decimal ASCII. Give a negative width to avoid
-// zero-padding.
-func itoa(buf *[]byte, i int, wid int) {
-	// Assemble decimal in reverse order.
-	var b [20]byte
-	bp := len(b) - 1
-	for i >= 10 || wid > 1 {
-		wid--
-		q := i / 10
-		b[bp] = byte('0' + i - q*10)
-		bp--
-		i = q
+// fileName, given the fully qualified file name, returns the file name as it
+// is to be logged as per Logger.flag. If Llongfile is specified, the base
+// path prefix (if any) is truncated.
+func (l *Logger) fileName(file string) string {
+	if l.flag&(Lshortfile|Llongfile) == 0 {
+		return file
+	}
+
+	// The base path prefix is only dropped if present. Consider project path
+	// is defined to be [...]/app/pkg/subpkg (read: not the project root), and
+	// is used at the top level, [...]/app/main.go, the file name is written
+	// out in full. The same goes for files that couldn't be determined
+	// ("[???]"), for e.g. for statements logged through standard library
+	// loggers whose headers couldn't be parsed (see NewStdLogger).
+	if len(l.basePath) != 0 && strings.HasPrefix(file, l.basePath+"/") {
+		// +1 is for leading '/', if basePath is non-empty.
+		file = file[len(l.basePath)+1:]
+	}
+
+	if l.flag&Lshortfile != 0 {
+		short := file
+		for i := len(file) - 1; i > 0; i-- {
+			if file[i] == '/' {
+				short = file[i+1:]
+				break
+			}
+		}
+		file = short
 	}
-	// i < 10
-	b[bp] = byte('0' + i)
-	*buf = append(*buf, b[bp:]...)
+	return file
 }
 
 // stacktrace returns the stack trace for the current goroutine, skipping n
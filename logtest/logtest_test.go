@@ -0,0 +1,157 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/irfansharif/log"
+)
+
+// scopeTB is a testing.TB that records cleanups and logs, for TestScope.
+type scopeTB struct {
+	testing.TB
+	failed   bool
+	cleanups []func()
+	logs     []string
+}
+
+func (s *scopeTB) Cleanup(f func()) { s.cleanups = append(s.cleanups, f) }
+func (s *scopeTB) Failed() bool     { return s.failed }
+func (s *scopeTB) Logf(format string, args ...interface{}) {
+	s.logs = append(s.logs, fmt.Sprintf(format, args...))
+}
+func (s *scopeTB) Fatalf(format string, args ...interface{}) {
+	panic(fmt.Sprintf(format, args...))
+}
+
+func (s *scopeTB) cleanup() {
+	for i := len(s.cleanups) - 1; i >= 0; i-- {
+		s.cleanups[i]()
+	}
+}
+
+func TestScope(t *testing.T) {
+	for _, failed := range []bool{false, true} {
+		tb := &scopeTB{TB: t, failed: failed}
+		logger := log.New(log.Writer(Scope(tb)))
+		log.SetGlobalLogMode(log.DebugMode)
+		log.SetFileLogMode("s.go", log.WarnMode)
+		log.SetTracePoint("s.go:42")
+		logger.Debug("debug")
+
+		tb.cleanup()
+		if m := log.GetGlobalLogMode(); m != log.DefaultMode {
+			t.Errorf("expected global log mode to be restored to %s, got %s", log.DefaultMode, m)
+		}
+		if _, ok := log.GetFileLogMode("s.go"); ok {
+			t.Error("expected file log mode to be reset")
+		}
+		if log.GetTracePoint("s.go:42") {
+			t.Error("expected tracepoint to be reset")
+		}
+
+		dumped := len(tb.logs) == 1 && strings.Contains(tb.logs[0], "] debug")
+		if dumped != failed {
+			t.Errorf("expected captured logs to be dumped only on failure (failed=%t), got: %q", failed, tb.logs)
+		}
+	}
+
+	// Nested use is to fail the test, as opposed to deadlocking.
+	tb := &scopeTB{TB: t}
+	Scope(tb)
+	func() {
+		defer func() {
+			if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "using it already") {
+				t.Errorf("expected nested use of Scope to fail the test, got %v", r)
+			}
+		}()
+		Scope(tb)
+	}()
+	tb.cleanup()
+}
+
+func TestTestingWriter(t *testing.T) {
+	writer := TestingWriter(t)
+	logger := log.New(log.Writer(writer))
+
+	// XXX(irfansharif): This test depends on the exact difference in line
+	// numbers between the call to runtime.Caller and the logging statements
+	// below.
+	_, file, line, _ := runtime.Caller(0)
+	logger.Info("info")
+	logger.Warnf("warnf %d", 42)
+
+	entries := writer.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got: %v", entries)
+	}
+	expected := []log.Entry{
+		{Mode: log.InfoMode, File: filepath.Base(file), Line: line + 1, Message: "info"},
+		{Mode: log.WarnMode, File: filepath.Base(file), Line: line + 2, Message: "warnf 42"},
+	}
+	for i, e := range expected {
+		entries[i].Time = time.Time{}
+		if entries[i] != e {
+			t.Errorf("expected %+v, got %+v", e, entries[i])
+		}
+	}
+}
+
+// helperTB is a testing.TB that records the functions marked as test
+// helpers, for TestTestingWriterHelpers.
+type helperTB struct {
+	testing.TB
+	helpers []string
+}
+
+func (h *helperTB) Helper() {
+	pc, _, _, _ := runtime.Caller(1)
+	h.helpers = append(h.helpers, runtime.FuncForPC(pc).Name())
+}
+
+func TestTestingWriterHelpers(t *testing.T) {
+	// Loggers are to mark their internals as test helpers, and nothing else,
+	// even when writing out to wrapped TestWriters.
+	tb := &helperTB{TB: t}
+	writer := log.SynchronizedWriter(log.MultiWriter(ioutil.Discard, TestingWriter(tb)))
+	logger := log.New(log.Writer(writer))
+	logger.Info("info")
+
+	helpers := make(map[string]bool)
+	for _, h := range tb.helpers {
+		helpers[h] = true
+		if !strings.HasPrefix(h, "github.com/irfansharif/log.") &&
+			!strings.HasPrefix(h, "github.com/irfansharif/log/logtest.") {
+			t.Errorf("unexpected function marked as test helper: %s", h)
+		}
+	}
+	for _, h := range []string{
+		"github.com/irfansharif/log.(*Logger).Info",
+		"github.com/irfansharif/log.(*synchronizedWriter).Write",
+		"github.com/irfansharif/log.(*multiWriter).Write",
+		"github.com/irfansharif/log/logtest.(*TestWriter).Write",
+	} {
+		if !helpers[h] {
+			t.Errorf("expected %s to be marked as a test helper, got %v", h, tb.helpers)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logtest provides utilities for testing code that logs using
+// github.com/irfansharif/log. It's kept apart from the log package so that
+// programs importing the latter don't import package testing (and register
+// its flags) as well.
+package logtest
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/irfansharif/log"
+)
+
+var (
+	// scopeMu serializes tests using Scope, given they share the global
+	// state. It's held for the duration of the test using Scope, including
+	// any time spent paused in t.Parallel (see Scope).
+	scopeMu sync.Mutex
+
+	// scopeHolder is the name of the test holding scopeMu, if any.
+	scopeHolder struct {
+		sync.Mutex
+		name string
+	}
+)
+
+// Scope returns an io.Writer private to the provided test, for Loggers to
+// write out to, whose contents are dumped through t.Log only if the test
+// fails. The global state (the global log mode, file log modes and
+// tracepoints) is restored once the test completes, so tests are free to
+// change it without deferring resets:
+//
+//	func TestFoo(t *testing.T) {
+//	    logger := log.New(log.Writer(logtest.Scope(t)))
+//	    log.SetGlobalLogMode(log.DebugMode)
+//	    ...
+//	}
+//
+// Tests using Scope are serialized with one another, even when marked as
+// parallel, so as to not observe each other's changes to the global state.
+// Consequently Scope is to be used at most once per test, and not by the
+// subtests of a test using it (which would otherwise wait on their parent
+// to complete, and their parent on them). Doing so fails the test.
+//
+// Parallel tests are to call t.Parallel before Scope, never after: t.Parallel
+// pauses the test until the sequential ones complete, and a paused test
+// holding on to Scope deadlocks any sequential test using it.
+func Scope(t testing.TB) io.Writer {
+	t.Helper()
+
+	scopeHolder.Lock()
+	holder := scopeHolder.name
+	scopeHolder.Unlock()
+	if holder != "" && (holder == t.Name() || strings.HasPrefix(t.Name(), holder+"/")) {
+		t.Fatalf("logtest.Scope used within %s, which is using it already", holder)
+	}
+
+	scopeMu.Lock()
+	scopeHolder.Lock()
+	scopeHolder.name = t.Name()
+	scopeHolder.Unlock()
+
+	restore := log.SaveGlobalState()
+	buf := &scopeBuffer{}
+	t.Cleanup(func() {
+		restore()
+		scopeHolder.Lock()
+		scopeHolder.name = ""
+		scopeHolder.Unlock()
+		scopeMu.Unlock()
+
+		if t.Failed() && buf.Len() != 0 {
+			t.Logf("captured logs:\n%s", buf.String())
+		}
+	})
+	return buf
+}
+
+// scopeBuffer is the buffer returned by Scope, safe for concurrent use.
+type scopeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *scopeBuffer) Write(b []byte) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(b)
+}
+
+func (s *scopeBuffer) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Len()
+}
+
+func (s *scopeBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
@@ -0,0 +1,101 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtest
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/irfansharif/log"
+)
+
+// helper is implemented by testing.TB, see TestWriter.
+type helper interface {
+	Helper()
+}
+
+// TestWriter is an io.Writer forwarding log entries to a test's t.Log, see
+// TestingWriter.
+type TestWriter struct {
+	// The test's Helper method is promoted (as opposed to being wrapped), so
+	// that it marks its callers as test helpers, see log.Writer.
+	helper
+	t testing.TB
+
+	mu      sync.Mutex
+	done    bool        // Whether the test has completed
+	entries []log.Entry // Entries written out so far
+}
+
+// TestingWriter returns an io.Writer that forwards each log entry written out
+// to it to t.Log, so that it's attached to the right (sub)test and
+// interleaved with the test's own output under go test -v. Loggers
+// configured to write out to it (see log.Writer) mark their internals as
+// test helpers, so entries are attributed to the logging statement:
+//
+//	logger := log.New(log.Writer(logtest.TestingWriter(t)))
+//	logger.Info("info")
+//	...
+//	=== RUN   TestFoo
+//	    foo_test.go:42: I180419 06:33:04.606396 foo_test.go:42] info
+//
+// Entries are also retained for tests to assert on, see TestWriter.Entries.
+// Entries written out once the test has completed are retained but not
+// forwarded, as t.Log would otherwise panic.
+func TestingWriter(t testing.TB) *TestWriter {
+	w := &TestWriter{helper: t, t: t}
+	t.Cleanup(func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.done = true
+	})
+	return w
+}
+
+// Write implements io.Writer. Each write is expected to correspond to a
+// single log entry (or backtrace), as is the case when written out by a
+// Logger.
+func (w *TestWriter) Write(b []byte) (n int, err error) {
+	w.t.Helper()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	decoder := log.NewEntryDecoder(bytes.NewReader(b))
+	for {
+		var entry log.Entry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		w.entries = append(w.entries, entry)
+	}
+
+	if !w.done {
+		w.t.Log(strings.TrimSuffix(string(b), "\n"))
+	}
+	return len(b), nil
+}
+
+// Entries returns the log entries written out so far.
+func (w *TestWriter) Entries() []log.Entry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]log.Entry(nil), w.entries...)
+}
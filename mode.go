@@ -14,6 +14,12 @@
 
 package log
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // TODO(irfansharif): Comment, explain modal logging concept.
 type Mode int
 
@@ -64,3 +70,72 @@ func (m Mode) byte() byte {
 		return '?'
 	}
 }
+
+// String returns the textual representation of the mode, as accepted by
+// ParseMode. Modes are listed in the order they're declared, separated by
+// '|'; DisabledMode is represented as "disabled".
+func (m Mode) String() string {
+	if m == DisabledMode {
+		return "disabled"
+	}
+
+	var modes []string
+	if (m & InfoMode) != DisabledMode {
+		modes = append(modes, "info")
+	}
+	if (m & WarnMode) != DisabledMode {
+		modes = append(modes, "warn")
+	}
+	if (m & ErrorMode) != DisabledMode {
+		modes = append(modes, "error")
+	}
+	if (m & FatalMode) != DisabledMode {
+		modes = append(modes, "fatal")
+	}
+	if (m & DebugMode) != DisabledMode {
+		modes = append(modes, "debug")
+	}
+	return strings.Join(modes, "|")
+}
+
+// ParseMode parses the textual representation of a mode, a '|' separated list
+// of (info|warn|error|fatal|debug), or "disabled".
+func ParseMode(value string) (Mode, error) {
+	var m Mode
+	for _, mode := range strings.Split(value, "|") {
+		switch mode {
+		case "info":
+			m |= InfoMode
+		case "warn":
+			m |= WarnMode
+		case "error":
+			m |= ErrorMode
+		case "fatal":
+			m |= FatalMode
+		case "debug":
+			m |= DebugMode
+		case "disabled":
+			m |= DisabledMode
+		default:
+			return DisabledMode, errors.New(fmt.Sprintf("unrecognized mode: %s", mode))
+		}
+	}
+	return m, nil
+}
+
+// MarshalText implements encoding.TextMarshaler, using the textual
+// representation of the mode (see String).
+func (m Mode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing the textual
+// representation of the mode (see ParseMode).
+func (m *Mode) UnmarshalText(text []byte) error {
+	mode, err := ParseMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
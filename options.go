@@ -24,6 +24,8 @@
 package log
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"path/filepath"
 	"runtime"
@@ -61,11 +63,107 @@ const (
 	LstdFlags = Lmode | Ldate | Ltime | Lmicroseconds | LUTC | Lshortfile
 )
 
-// Writer configures a Logger instance with the specified io.Writer.
+// Format determines how log entries are encoded by a Logger instance.
+type Format int
+
+const (
+	// TextFormat encodes entries as the log header (as determined by the
+	// flags set) followed by the message, for e.g.:
+	//   I180419 06:33:04.606396 fname.go:42] message
+	TextFormat Format = iota
+
+	// JSONFormat encodes entries as JSON objects (see Entry), one per line.
+	// The file name is determined by Lshortfile, Llongfile and the base path
+	// as it is for TextFormat, as is the time zone by LUTC. For e.g.:
+	//   {"mode":"info","time":"2018-04-19T06:33:04.606396Z","file":"fname.go","line":42,"message":"message"}
+	// Backtraces for enabled tracepoints are included in the entries, see
+	// Entry.Stack.
+	JSONFormat
+)
+
+// String returns the textual representation of the format, as accepted by
+// ParseFormat.
+func (f Format) String() string {
+	switch f {
+	case TextFormat:
+		return "text"
+	case JSONFormat:
+		return "json"
+	default:
+		return "?"
+	}
+}
+
+// ParseFormat parses the textual representation of a format, one of
+// (text|json).
+func ParseFormat(value string) (Format, error) {
+	switch value {
+	case "text":
+		return TextFormat, nil
+	case "json":
+		return JSONFormat, nil
+	default:
+		return TextFormat, errors.New(fmt.Sprintf("unrecognized format: %s", value))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler, using the textual
+// representation of the format (see String).
+func (f Format) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, parsing the textual
+// representation of the format (see ParseFormat).
+func (f *Format) UnmarshalText(text []byte) error {
+	format, err := ParseFormat(string(text))
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+// OutputFormat configures the format log entries are encoded in by a Logger
+// instance.
+func OutputFormat(f Format) option {
+	return func(l *Logger) {
+		l.setFormat(f)
+	}
+}
+
+// Writer configures a Logger instance with the specified io.Writer. If the
+// writer implements interface{ Helper() } (as is done by
+// logtest.TestingWriter, for e.g.), the Logger marks its internals as test
+// helpers through it, so that test output is attributed to the logging
+// statement. This applies to writers wrapped using SynchronizedWriter and
+// MultiWriter as well (the first one found, for the latter).
 func Writer(w io.Writer) option {
 	return func(l *Logger) {
 		l.w = w
+		l.helper = writerHelper(w)
+		if l.helper == nil {
+			l.helper = func() {}
+		}
+	}
+}
+
+// writerHelper returns the Helper method of the provided writer, or of the
+// one it wraps, if any.
+func writerHelper(w io.Writer) func() {
+	switch w := w.(type) {
+	case interface{ Helper() }:
+		return w.Helper
+	case *synchronizedWriter:
+		return writerHelper(w.w)
+	case *multiWriter:
+		for _, w := range w.ws {
+			if helper := writerHelper(w); helper != nil {
+				return helper
+			}
+		}
 	}
+	return nil
 }
 
 // Flags configures the header format for all logs emitted by a Logger instance.
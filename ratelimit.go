@@ -0,0 +1,174 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// Every returns a Logger that emits at most one entry per call site (read:
+// logging statement) every d, suppressing the rest. When an entry is next
+// emitted for a call site, it's preceded by one noting the number of entries
+// suppressed in the interim:
+//
+//	W180419 06:33:04.606396 fname.go:42] suppressed 12 similar messages from fname.go:42
+//
+// The returned Logger shares its state with the one it's derived from, so
+// it's intended to be used inline:
+//
+//	for {
+//	    if err := retry(); err != nil {
+//	        logger.Every(time.Second).Warn(err)
+//	    }
+//	}
+//
+// Entries filtered out by log modes don't count against the limit. The
+// returned Logger replaces any limit (see RateLimit, EveryN) the Logger was
+// configured with.
+func (l *Logger) Every(d time.Duration) *Logger {
+	nl := *l
+	nl.limit = limit{every: d}
+	return &nl
+}
+
+// EveryN returns a Logger that only emits every n-th entry per call site
+// (starting with the first), suppressing the rest. See Every for how the
+// returned Logger is to be used, and how suppressed entries are reported.
+func (l *Logger) EveryN(n int) *Logger {
+	nl := *l
+	nl.limit = limit{n: n}
+	return &nl
+}
+
+// RateLimit configures a Logger instance to rate limit the entries emitted
+// per call site using a token bucket, allowing for bursts of up to the
+// specified size (at least one) and for rate entries per second thereafter.
+// Suppressed entries are reported as they are for Every.
+func RateLimit(rate float64, burst int) option {
+	if burst < 1 {
+		burst = 1
+	}
+	return func(l *Logger) {
+		l.limit = limit{rate: rate, burst: burst}
+	}
+}
+
+// limit is the per call site rate limit a Logger is configured with. At most
+// one of the fields is to be set, the zero value indicating no limit.
+type limit struct {
+	every time.Duration // See Logger.Every
+	n     int           // See Logger.EveryN
+	rate  float64       // See RateLimit
+	burst int           // See RateLimit
+}
+
+// callSite identifies a logging statement, as limited by a specific limit.
+type callSite struct {
+	limit limit
+	file  string
+	line  int
+}
+
+type callSiteState struct {
+	seen       time.Time // Time the last entry was logged
+	last       time.Time // Time the last entry was emitted, or tokens last replenished
+	count      int       // Number of entries seen, for EveryN
+	tokens     float64   // Tokens available, for RateLimit
+	suppressed int       // Number of entries suppressed since the last one emitted
+}
+
+// callSiteIdleTimeout is how long a call site's rate limiting state is
+// retained for after its last entry was logged, provided the limit would
+// allow for the next entry regardless. Count of suppressed entries, and those
+// seen for EveryN, are forgotten thereafter.
+var callSiteIdleTimeout = 5 * time.Minute
+
+// callSites tracks the per call site rate limiting state.
+type callSites struct {
+	sync.Mutex
+	m     map[callSite]*callSiteState
+	swept time.Time // Time idle call sites were last evicted
+}
+
+// allow determines whether the entry logged at the provided call site at the
+// provided time is to be emitted as per the specified limit, returning the
+// number of entries suppressed since the last one emitted if so.
+func (c *callSites) allow(lim limit, file string, line int, now time.Time) (allowed bool, suppressed int) {
+	c.Lock()
+	defer c.Unlock()
+
+	if now.Sub(c.swept) >= callSiteIdleTimeout {
+		c.evictIdle(now)
+	}
+
+	site := callSite{limit: lim, file: file, line: line}
+	s, ok := c.m[site]
+	if !ok {
+		s = &callSiteState{tokens: float64(lim.burst)}
+		c.m[site] = s
+	}
+	s.seen = now
+
+	switch {
+	case lim.every != 0:
+		allowed = !ok || now.Sub(s.last) >= lim.every
+		if allowed {
+			s.last = now
+		}
+	case lim.n != 0:
+		allowed = s.count%lim.n == 0
+		s.count++
+	default:
+		if ok {
+			s.tokens += now.Sub(s.last).Seconds() * lim.rate
+			if s.tokens > float64(lim.burst) {
+				s.tokens = float64(lim.burst)
+			}
+		}
+		s.last = now
+		allowed = s.tokens >= 1
+		if allowed {
+			s.tokens--
+		}
+	}
+
+	if !allowed {
+		s.suppressed++
+		return false, 0
+	}
+	suppressed, s.suppressed = s.suppressed, 0
+	return true, suppressed
+}
+
+// evictIdle evicts the state for call sites that have been idle for
+// callSiteIdleTimeout, so that it isn't retained indefinitely for every
+// logging statement ever rate limited. It's to be called with the mutex held.
+func (c *callSites) evictIdle(now time.Time) {
+	c.swept = now
+	for site, s := range c.m {
+		if now.Sub(s.seen) < callSiteIdleTimeout {
+			continue
+		}
+		lim := site.limit
+		if lim.every != 0 && now.Sub(s.last) < lim.every {
+			continue // The next entry is yet to be suppressed.
+		}
+		if lim.rate != 0 && s.tokens+now.Sub(s.last).Seconds()*lim.rate < float64(lim.burst) {
+			continue // The bucket is yet to be replenished.
+		}
+		delete(c.m, site)
+	}
+}
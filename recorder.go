@@ -0,0 +1,112 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// FlightRecorder configures a Logger instance to retain the last n entries
+// logged through it in memory, at all modes, including those filtered out
+// by the global and file log modes (or suppressed by rate limits). The
+// entries not already written out are dumped to the Logger's writer when a
+// Fatal entry is logged (ahead of it), or on demand (see
+// Logger.DumpFlightRecorder, AdminFlightRecorder). This way a service can log
+// at, say, WarnMode, while still having debug context around crashes.
+//
+// Dumped entries retain their original headers (and thus timestamps), so
+// logcat interleaves them with the rest when reading the log files back. A
+// non-positive n disables the flight recorder.
+func FlightRecorder(n int) option {
+	return func(l *Logger) {
+		l.recorder = nil
+		if n > 0 {
+			l.recorder = &flightRecorder{entries: make([]recordedEntry, n)}
+		}
+	}
+}
+
+// recordedEntry is an entry retained by the flight recorder.
+type recordedEntry struct {
+	mode    Mode
+	t       time.Time
+	file    string // Fully qualified
+	line    int
+	data    string
+	emitted bool // Whether the entry was written out already
+}
+
+// flightRecorder is a ring buffer of the last entries logged through a
+// Logger, see FlightRecorder.
+type flightRecorder struct {
+	sync.Mutex
+	entries []recordedEntry
+	next    int // Index the next entry is to be recorded at
+	full    bool
+}
+
+// record records the provided entry, evicting the oldest one if full.
+func (r *flightRecorder) record(e recordedEntry) {
+	r.Lock()
+	defer r.Unlock()
+
+	if len(r.entries) == 0 {
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// pending returns the recorded entries not already written out, oldest
+// first, marking them as written out if specified.
+func (r *flightRecorder) pending(markEmitted bool) []recordedEntry {
+	r.Lock()
+	defer r.Unlock()
+
+	start, n := 0, r.next
+	if r.full {
+		start, n = r.next, len(r.entries)
+	}
+	var pending []recordedEntry
+	for i := 0; i < n; i++ {
+		e := &r.entries[(start+i)%len(r.entries)]
+		if e.emitted {
+			continue
+		}
+		pending = append(pending, *e)
+		if markEmitted {
+			e.emitted = true
+		}
+	}
+	return pending
+}
+
+// DumpFlightRecorder writes out the entries retained by the Logger's flight
+// recorder (see FlightRecorder) that weren't already, returning how many
+// were. It's a no-op for Loggers not configured with one.
+func (l *Logger) DumpFlightRecorder() int {
+	if l.recorder == nil {
+		return 0
+	}
+	pending := l.recorder.pending(true)
+	for _, e := range pending {
+		l.emit(e.mode, e.t, e.file, e.line, e.data, nil)
+	}
+	return len(pending)
+}
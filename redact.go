@@ -0,0 +1,174 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Markers enclosing potentially sensitive values in log entries written out
+// by Loggers configured using RedactableOutput.
+const (
+	startRedactable = "‹"
+	endRedactable   = "›"
+
+	redacted = startRedactable + "×" + endRedactable
+)
+
+// SafeMessager is implemented by types whose textual representation, as
+// returned by SafeMessage, is safe to log as is (read: doesn't contain
+// sensitive data). Such arguments are formatted as the string returned when
+// formatted using %s or %v, and as they otherwise would be for other verbs.
+type SafeMessager interface {
+	SafeMessage() string
+}
+
+// SafeType wraps a value considered safe to log as is, see Safe.
+type SafeType struct {
+	v interface{}
+}
+
+// Safe marks the provided value as safe to log as is, so that it isn't
+// marked for redaction when logged by Loggers configured using
+// RedactableOutput:
+//
+//	logger.Infof("%d ranges in %s", log.Safe(len(ranges)), key)
+//	I180419 06:33:04.606396 fname.go:42] 42 ranges in ‹/Table/51/1›
+func Safe(v interface{}) SafeType {
+	return SafeType{v: v}
+}
+
+// SafeMessage implements SafeMessager.
+func (st SafeType) SafeMessage() string {
+	return fmt.Sprint(st.v)
+}
+
+// String implements fmt.Stringer.
+func (st SafeType) String() string {
+	return st.SafeMessage()
+}
+
+// Format implements fmt.Formatter, formatting the wrapped value as if it
+// were passed in directly.
+func (st SafeType) Format(s fmt.State, verb rune) {
+	fmt.Fprintf(s, fmt.FormatString(s, verb), st.v)
+}
+
+// RedactableOutput configures a Logger instance to enclose the arguments of
+// each logging statement within ‹› markers, unless wrapped using Safe or
+// implementing SafeMessager, so that they can be redacted later on (see
+// Redact):
+//
+//	logger.Infof("user %s logged in", username)
+//	I180419 06:33:04.606396 fname.go:42] user ‹irfansharif› logged in
+//
+// Format strings are considered safe. Markers occurring within the arguments
+// themselves are escaped. Entries written out through NewStdLogger (already
+// formatted) are marked for redaction in their entirety.
+func RedactableOutput() option {
+	return func(l *Logger) {
+		l.redactable = true
+	}
+}
+
+// redactableArg wraps an argument to be marked for redaction when formatted.
+type redactableArg struct {
+	v interface{}
+}
+
+// Format implements fmt.Formatter, formatting the wrapped value as if it
+// were passed in directly, within redaction markers.
+func (a redactableArg) Format(s fmt.State, verb rune) {
+	s.Write([]byte(markRedactable(fmt.Sprintf(fmt.FormatString(s, verb), a.v))))
+}
+
+// safeMessagerArg wraps a SafeMessager argument, considered safe to log as
+// is.
+type safeMessagerArg struct {
+	v SafeMessager
+}
+
+// Format implements fmt.Formatter, formatting the wrapped value as the string
+// returned by SafeMessage for %s and %v (respecting the width, precision and
+// '-' flag), and as if it were passed in directly otherwise. Nil pointers are
+// formatted as fmt does, as opposed to calling SafeMessage on them.
+func (a safeMessagerArg) Format(s fmt.State, verb rune) {
+	if (verb == 's' || verb == 'v') && !s.Flag('+') && !s.Flag('#') && !isNilPointer(a.v) {
+		fmt.Fprintf(s, fmt.FormatString(s, verb), a.v.SafeMessage())
+		return
+	}
+	fmt.Fprintf(s, fmt.FormatString(s, verb), a.v)
+}
+
+// isNilPointer returns whether the provided value is a nil pointer.
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// markRedactable encloses the provided value within redaction markers,
+// escaping any it already contains.
+func markRedactable(v string) string {
+	return startRedactable + redactableEscaper.Replace(v) + endRedactable
+}
+
+var redactableEscaper = strings.NewReplacer(startRedactable, "?", endRedactable, "?")
+
+// redactableArgs wraps the provided arguments for them to be marked for
+// redaction when formatted, with the exception of those considered safe.
+func redactableArgs(v []interface{}) []interface{} {
+	args := make([]interface{}, len(v))
+	for i, arg := range v {
+		switch arg := arg.(type) {
+		case SafeType:
+			args[i] = arg
+		case SafeMessager:
+			args[i] = safeMessagerArg{v: arg}
+		default:
+			args[i] = redactableArg{v: arg}
+		}
+	}
+	return args
+}
+
+// Logger.sprint formats the provided arguments in the manner of fmt.Sprintln,
+// marking them for redaction if the Logger is configured to.
+func (l *Logger) sprint(v ...interface{}) string {
+	if l.redactable {
+		v = redactableArgs(v)
+	}
+	return fmt.Sprintln(v...)
+}
+
+// Logger.sprintf formats the provided arguments in the manner of
+// fmt.Sprintf, marking them for redaction if the Logger is configured to.
+func (l *Logger) sprintf(format string, v ...interface{}) string {
+	if l.redactable {
+		v = redactableArgs(v)
+	}
+	return fmt.Sprintf(format, v...)
+}
+
+var redactableRegex = regexp.MustCompile(startRedactable + `[^` + startRedactable + endRedactable + `]*` + endRedactable)
+
+// Redact replaces every value marked for redaction in the provided log
+// message (as written out by Loggers configured using RedactableOutput) with
+// ‹×›.
+func Redact(message string) string {
+	return redactableRegex.ReplaceAllLiteralString(message, redacted)
+}
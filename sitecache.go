@@ -0,0 +1,106 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+)
+
+// siteDecision is the resolved filtering and tracepoint decision for logging
+// statements of a given mode at a given call site, as of a given generation
+// of the global state (see gstateT.gen).
+type siteDecision struct {
+	pc    uintptr
+	lmode Mode
+	gen   uint64
+
+	file      string // Fully qualified
+	line      int
+	bfile     string // Base name, see Logger.output
+	tp        string // Tracepoint, of the form fname.go:line-number
+	enabled   bool   // Whether entries are to be written out, see shouldLog
+	tpenabled bool   // Whether the tracepoint is enabled
+}
+
+// siteCacheSize is the number of call site decisions cached. Call sites
+// hashing to the same slot evict one another, so it's sized to hold the
+// logging statements of most programs without many collisions.
+const siteCacheSize = 1 << 10
+
+// siteCache caches call site decisions by program counter, so that logging
+// statements that are filtered out don't have to be resolved to their file
+// and line every time around. Each slot holds a *siteDecision; entries are
+// never mutated once stored, only replaced.
+var siteCache [siteCacheSize]atomic.Value
+
+// filteredOut returns whether entries of the provided mode are filtered out
+// regardless of their call site, i.e. the mode isn't included in the global
+// log mode and neither file log modes nor tracepoints are set. It allows for
+// such logging statements to skip resolving their call site altogether.
+func filteredOut(lmode Mode) bool {
+	return (GetGlobalLogMode()&lmode) == DisabledMode && (lmode&FatalMode) == DisabledMode &&
+		len(gstate.fileModeMu.m.Load().(fileModeMap)) == 0 &&
+		len(gstate.tracePointMu.m.Load().(tracePointMap)) == 0
+}
+
+// resolveSite returns the decision for logging statements of the provided
+// mode at the call site with the provided program counter (as returned by
+// runtime.Callers), using the cached one unless the global state has since
+// changed.
+func resolveSite(pc uintptr, lmode Mode) *siteDecision {
+	// The generation is loaded before the global state is consulted, so
+	// that decisions racing with updates are tagged as stale.
+	gen := atomic.LoadUint64(&gstate.gen)
+	slot := &siteCache[(pc^uintptr(lmode)*0x9e3779b9)%siteCacheSize]
+	if d, ok := slot.Load().(*siteDecision); ok && d.pc == pc && d.lmode == lmode && d.gen == gen {
+		return d
+	}
+
+	file, line := "[???]", -1
+	if frame, _ := runtime.CallersFrames([]uintptr{pc}).Next(); frame.File != "" {
+		file, line = frame.File, frame.Line
+	}
+	d := newSiteDecision(lmode, file, line)
+	d.pc, d.gen = pc, gen
+	slot.Store(d)
+	return d
+}
+
+// newSiteDecision resolves the decision for logging statements of the
+// provided mode at the provided file (fully qualified) and line, as per the
+// current global state.
+func newSiteDecision(lmode Mode, file string, line int) *siteDecision {
+	// TODO(irfansharif): Right now this isn't robust to shared filenames
+	// across varied sub packages for tracepoints (file log modes can be
+	// disambiguated using patterns, see SetFileLogMode).
+	// This is a stand-in to allow for direct file name specification without
+	// fully-specified paths (in the host machine or relative to project root).
+	// We could implement for project root relative paths if project root was
+	// provided.
+	bfile := filepath.Base(file)
+	tp := fmt.Sprintf("%s:%d", bfile, line)
+	return &siteDecision{
+		lmode:     lmode,
+		file:      file,
+		line:      line,
+		bfile:     bfile,
+		tp:        tp,
+		enabled:   shouldLog(lmode, file),
+		tpenabled: GetTracePoint(tp),
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// NewSlogHandler returns a slog.Handler that writes out records through the
+// provided Logger. Levels map to modes as follows:
+//
+//	slog.LevelDebug (and below)    DebugMode
+//	slog.LevelInfo                 InfoMode
+//	slog.LevelWarn                 WarnMode
+//	slog.LevelError (and above)    ErrorMode
+//
+// Records are attributed to their source location (as captured by slog), and
+// are subject to file log modes and tracepoints as usual. Attributes are
+// rendered after the message as key=value pairs, with keys within groups
+// qualified by the group name, for e.g.:
+//
+//	I180419 06:33:04.606396 fname.go:42] message key=value group.key="another value"
+//
+// If the Logger is configured using RedactableOutput, attribute values are
+// marked for redaction (unless implementing SafeMessager); messages are
+// considered safe.
+func NewSlogHandler(l *Logger) slog.Handler {
+	return &slogHandler{l: l}
+}
+
+type slogHandler struct {
+	l      *Logger
+	attrs  string // Attributes added using WithAttrs, pre-rendered
+	prefix string // Key prefix for the groups added using WithGroup, if any
+}
+
+// slogMode returns the mode the provided slog level maps to.
+func slogMode(level slog.Level) Mode {
+	switch {
+	case level < slog.LevelInfo:
+		return DebugMode
+	case level < slog.LevelWarn:
+		return InfoMode
+	case level < slog.LevelError:
+		return WarnMode
+	default:
+		return ErrorMode
+	}
+}
+
+// Enabled implements slog.Handler. Given the source location isn't known at
+// this point, a level is considered enabled if it's included in the global
+// log mode or any file log mode, or if any tracepoint is enabled or the
+// Logger is configured with a flight recorder (records filtered out are
+// recorded nonetheless); Handle filters out records precisely.
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	mode := slogMode(level)
+	if (GetGlobalLogMode() & mode) != DisabledMode {
+		return true
+	}
+	if h.l.recorder != nil || len(gstate.tracePointMu.m.Load().(tracePointMap)) != 0 {
+		return true
+	}
+	for _, fmode := range gstate.fileModeMu.m.Load().(fileModeMap) {
+		if (fmode & mode) != DisabledMode {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle implements slog.Handler.
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	mode := slogMode(r.Level)
+	var site *siteDecision
+	if r.PC != 0 {
+		site = resolveSite(r.PC, mode)
+	} else {
+		site = newSiteDecision(mode, "[???]", -1)
+	}
+	if h.l.recorder == nil && !site.enabled && !site.tpenabled {
+		return nil // Filtered out, see Enabled.
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(r.Message)
+	buf.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendSlogAttr(&buf, h.prefix, a, h.l.redactable)
+		return true
+	})
+
+	// Skip slogHandler.Handle, and slog's Logger.log and the invoking public
+	// wrapper, slog.{Debug,Info,Warn,Error,Log}{,Context}.
+	h.l.outputSite(site, 3, buf.String())
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var buf bytes.Buffer
+	buf.WriteString(h.attrs)
+	for _, a := range attrs {
+		appendSlogAttr(&buf, h.prefix, a, h.l.redactable)
+	}
+	nh := *h
+	nh.attrs = buf.String()
+	return &nh
+}
+
+// WithGroup implements slog.Handler.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	nh := *h
+	nh.prefix = h.prefix + name + "."
+	return &nh
+}
+
+// appendSlogAttr renders the provided attribute as " key=value", qualifying
+// the key with the provided prefix. Group attributes are rendered as each of
+// their attributes, qualified by the group name (unless empty). Values are
+// marked for redaction if specified, unless implementing SafeMessager.
+func appendSlogAttr(buf *bytes.Buffer, prefix string, a slog.Attr, redactable bool) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return // Ignored, as per the slog.Handler contract.
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix = prefix + a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			appendSlogAttr(buf, prefix, ga, redactable)
+		}
+		return
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(prefix)
+	buf.WriteString(a.Key)
+	buf.WriteByte('=')
+	value := quoteIfNeeded(a.Value.String())
+	if redactable {
+		if m, ok := a.Value.Any().(SafeMessager); ok {
+			value = quoteIfNeeded(m.SafeMessage())
+		} else {
+			value = markRedactable(value)
+		}
+	}
+	buf.WriteString(value)
+}
+
+// quoteIfNeeded quotes the provided value if it's empty, or contains spaces,
+// quotes, '=' or non-printable characters, so that rendered attributes can be
+// told apart.
+func quoteIfNeeded(value string) string {
+	if value == "" || strings.IndexFunc(value, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) >= 0 {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,85 @@
+//go:build go1.21
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	SetGlobalLogMode(InfoMode)
+	defer SetGlobalLogMode(DefaultMode)
+
+	buffer := new(bytes.Buffer)
+	logger := slog.New(NewSlogHandler(New(Writer(buffer))))
+	{
+		// XXX(irfansharif): This test depends on the call to caller and the
+		// logging statement below being on consecutive lines.
+		_, line := caller(0)
+		logger.With("k", "v").WithGroup("g").Info("info", "a", 1, slog.Group("h", "b", "c d"))
+
+		regex := fmt.Sprintf("^I.* slog_test.go:%d\\] info k=v g.a=1 g.h.b=\"c d\"\n$", line+1)
+		match, err := regexp.Match(regex, buffer.Bytes())
+		if err != nil {
+			t.Error(err)
+		}
+		if !match {
+			t.Errorf("expected pattern: \"%s\", got: %s", regex, buffer.String())
+		}
+		buffer.Reset()
+	}
+	{
+		logger.Debug("debug")
+		if buffer.Len() != 0 {
+			t.Errorf("expected debug record to be filtered out, got: %s", buffer.String())
+		}
+	}
+	{
+		// Tracepoints fire for records filtered out by log modes.
+		_, line := caller(0)
+		tp := fmt.Sprintf("slog_test.go:%d", line+3)
+		SetTracePoint(tp)
+		logger.Debug("traced")
+		ResetTracePoint(tp)
+
+		regex := "^goroutine [\\d]+ \\[running\\]:\n"
+		match, err := regexp.Match(regex, buffer.Bytes())
+		if err != nil {
+			t.Error(err)
+		}
+		if !match {
+			t.Errorf("expected pattern: \"%s\", got: %s", regex, buffer.String())
+		}
+		buffer.Reset()
+	}
+	{
+		// As does the flight recorder.
+		recorded := slog.New(NewSlogHandler(New(Writer(buffer), FlightRecorder(1))))
+		recorded.Debug("recorded")
+		if buffer.Len() != 0 {
+			t.Errorf("expected debug record to be filtered out, got: %s", buffer.String())
+		}
+		handler := recorded.Handler().(*slogHandler)
+		if n := handler.l.DumpFlightRecorder(); n != 1 || !bytes.Contains(buffer.Bytes(), []byte("] recorded\n")) {
+			t.Errorf("expected debug record to be recorded, got: %s", buffer.String())
+		}
+		buffer.Reset()
+	}
+	SetFileLogMode("slog_test.go", DebugMode)
+	defer ResetFileLogMode("slog_test.go")
+	{
+		logger.Debug("debug")
+		regex := "^D.* slog_test.go:[\\d]+\\] debug\n$"
+		match, err := regexp.Match(regex, buffer.Bytes())
+		if err != nil {
+			t.Error(err)
+		}
+		if !match {
+			t.Errorf("expected pattern: \"%s\", got: %s", regex, buffer.String())
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// OutputStats tracks the entries written out, across all Loggers, for a
+// given mode or writer.
+type OutputStats struct {
+	Lines       int64 `json:"lines"`        // Entries written out
+	Bytes       int64 `json:"bytes"`        // Bytes written out
+	Dropped     int64 `json:"dropped"`      // Entries suppressed by rate limits (see Every, RateLimit) or Dedup
+	WriteErrors int64 `json:"write_errors"` // Entries whose writes failed
+}
+
+// Statistics are the output statistics, as returned by Stats.
+type Statistics struct {
+	Modes   map[Mode]OutputStats   `json:"modes"`   // Per mode, one of InfoMode, WarnMode, etc.
+	Writers map[string]OutputStats `json:"writers"` // Per writer, as named by WriterName
+}
+
+// outputStats is the concurrency-safe counterpart of OutputStats.
+type outputStats struct {
+	lines, bytes, dropped, writeErrors int64 // Accessed atomically
+}
+
+func (s *outputStats) snapshot() OutputStats {
+	return OutputStats{
+		Lines:       atomic.LoadInt64(&s.lines),
+		Bytes:       atomic.LoadInt64(&s.bytes),
+		Dropped:     atomic.LoadInt64(&s.dropped),
+		WriteErrors: atomic.LoadInt64(&s.writeErrors),
+	}
+}
+
+var gstats struct {
+	modes map[Mode]*outputStats // Immutable once initialized
+
+	writersMu sync.Mutex
+	writers   map[string]*outputStats
+}
+
+func init() {
+	gstats.modes = make(map[Mode]*outputStats)
+	for _, m := range []Mode{InfoMode, WarnMode, ErrorMode, FatalMode, DebugMode} {
+		gstats.modes[m] = &outputStats{}
+	}
+	gstats.writers = make(map[string]*outputStats)
+}
+
+// writerStats returns the stats for the writer with the provided name,
+// registering it if needed.
+func writerStats(name string) *outputStats {
+	gstats.writersMu.Lock()
+	defer gstats.writersMu.Unlock()
+
+	s, ok := gstats.writers[name]
+	if !ok {
+		s = &outputStats{}
+		gstats.writers[name] = s
+	}
+	return s
+}
+
+// Stats returns the output statistics, per mode and per writer, accumulated
+// across all Loggers since the process started.
+func Stats() Statistics {
+	stats := Statistics{
+		Modes:   make(map[Mode]OutputStats, len(gstats.modes)),
+		Writers: make(map[string]OutputStats),
+	}
+	for m, s := range gstats.modes {
+		stats.Modes[m] = s.snapshot()
+	}
+
+	gstats.writersMu.Lock()
+	defer gstats.writersMu.Unlock()
+	for name, s := range gstats.writers {
+		stats.Writers[name] = s.snapshot()
+	}
+	return stats
+}
+
+// PublishStats publishes the output statistics (see Stats) as an expvar
+// variable under the provided name, for e.g. "log". Like expvar.Publish, it
+// panics if the name is already in use.
+func PublishStats(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return Stats()
+	}))
+}
+
+// WriterName configures the name the Logger instance's writer is identified
+// by in the output statistics (see Stats). Loggers configured with the same
+// name share the same statistics. Entries written out by Loggers not
+// configured with a name are only accounted for in the per mode statistics.
+func WriterName(name string) option {
+	return func(l *Logger) {
+		l.writerName = name
+	}
+}
+
+// Logger.recordWrite records an entry of the provided mode being written out
+// by the Logger, having written out n bytes and failed with err, if non-nil.
+func (l *Logger) recordWrite(lmode Mode, n int, err error) {
+	for _, s := range [...]*outputStats{gstats.modes[lmode], l.wstats} {
+		if s == nil {
+			continue
+		}
+		if err != nil {
+			atomic.AddInt64(&s.writeErrors, 1)
+		} else {
+			atomic.AddInt64(&s.lines, 1)
+		}
+		atomic.AddInt64(&s.bytes, int64(n))
+	}
+}
+
+// Logger.recordDrop records an entry of the provided mode being suppressed.
+func (l *Logger) recordDrop(lmode Mode) {
+	for _, s := range [...]*outputStats{gstats.modes[lmode], l.wstats} {
+		if s == nil {
+			continue
+		}
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+// registerWriterStats resolves the statistics for the Logger's writer, as
+// named by WriterName. Unnamed writers aren't tracked individually; keying
+// them by type would lump unrelated sinks together (every *os.File, say), and
+// keying them by identity would grow the statistics with every Logger.
+func (l *Logger) registerWriterStats() {
+	l.wstats = nil
+	if l.writerName != "" {
+		l.wstats = writerStats(l.writerName)
+	}
+}
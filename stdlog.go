@@ -0,0 +1,72 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	stdlog "log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NewStdLogger returns a standard library *log.Logger that writes out
+// through the provided Logger at the specified mode. Each line written out
+// is re-parsed, so the entries are attributed to the standard library
+// logger's call site (as opposed to this package's internals), and are
+// subject to file log modes and tracepoints as usual.
+//
+// Changing the returned logger's flags or prefix breaks the re-parsing, at
+// which point entries are attributed to an unknown file.
+func NewStdLogger(l *Logger, mode Mode) *stdlog.Logger {
+	return stdlog.New(&stdWriter{l: l, mode: mode}, "", stdlog.Llongfile)
+}
+
+// CopyStandardLogTo redirects the output of the standard library's global
+// logger (as used by log.Print, etc.) through the provided Logger at the
+// specified mode, as done for loggers returned by NewStdLogger. It overrides
+// the global logger's flags and prefix.
+func CopyStandardLogTo(l *Logger, mode Mode) {
+	stdlog.SetPrefix("")
+	stdlog.SetFlags(stdlog.Llongfile)
+	stdlog.SetOutput(&stdWriter{l: l, mode: mode})
+}
+
+// stdLineRegex matches lines written out by standard library loggers
+// configured with only the log.Llongfile flag, capturing the file, line
+// number and message.
+var stdLineRegex = regexp.MustCompile(`(?s)^(.+?\.go):(\d+): (.*)$`)
+
+// stdWriter is the io.Writer standard library loggers are configured to
+// write out to, each Write corresponding to a single logging statement.
+type stdWriter struct {
+	l    *Logger
+	mode Mode
+}
+
+func (w *stdWriter) Write(b []byte) (n int, err error) {
+	file, line, data := "[???]", -1, string(b)
+	if matches := stdLineRegex.FindSubmatch(b); matches != nil {
+		file, data = string(matches[1]), string(matches[3])
+		line, _ = strconv.Atoi(string(matches[2])) // The regex guarantees a well-formed integer.
+	}
+	if w.l.redactable {
+		data = markRedactable(strings.TrimSuffix(data, "\n")) + "\n"
+	}
+
+	// Skip stdWriter.Write, and the standard library's Logger.output and the
+	// invoking public wrapper, log.{Print,Fatal,Panic}{,f,ln}.
+	w.l.output(w.mode, file, line, 3, data)
+	return len(b), nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Bounds for the delay between attempts to reconnect to the collector, and
+// for how long connecting to it or writing out to it can take, see
+// StreamWriter.
+var (
+	streamMinBackoff   = 100 * time.Millisecond
+	streamMaxBackoff   = 30 * time.Second
+	streamDialTimeout  = 5 * time.Second
+	streamWriteTimeout = 5 * time.Second
+)
+
+// StreamWriter is an io.Writer that streams log entries to a collector over
+// TCP, spooling them to local disk while disconnected. See NewStreamWriter.
+type StreamWriter struct {
+	addr         string
+	spoolDir     string
+	writeTimeout time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn           // Current connection, nil if disconnected
+	spool   *logRotationWriter // Writes out to the spool directory
+	closed  bool
+	stopper chan struct{} // Closed when the writer is, to stop reconnecting
+}
+
+// NewStreamWriter returns an io.Writer that streams the log entries written
+// out to it, as is, to the collector listening on the provided TCP address.
+// While disconnected, entries are spooled to rotating log files within the
+// provided directory (see LogRotationWriter) as the connection is retried
+// with exponential backoff. Once reconnected, the spooled files are replayed
+// in order before streaming resumes, and removed thereafter. Spooled files
+// left behind by previous processes (think restarts while the collector was
+// unreachable) are replayed as well.
+//
+// Writes to the collector time out after a few seconds, so a stalled
+// collector is treated as a disconnected one. Spooled entries are delivered
+// at least once: a spooled file that's only partially replayed before the
+// connection is lost again is replayed in its entirety the next time around.
+// Streamed entries are delivered at most once: a write only hands the entry
+// off to the kernel, so entries written shortly before the connection is
+// lost (think collector restarts) may never reach the collector, and aren't
+// spooled either.
+func NewStreamWriter(addr, spoolDir string) *StreamWriter {
+	os.MkdirAll(spoolDir, os.ModePerm)
+	w := &StreamWriter{
+		addr:         addr,
+		spoolDir:     spoolDir,
+		writeTimeout: streamWriteTimeout,
+		spool:        LogRotationWriter(spoolDir, 50<<20 /* 50 MiB */).(*logRotationWriter),
+		stopper:      make(chan struct{}),
+	}
+
+	w.mu.Lock()
+	w.reconnect()
+	w.mu.Unlock()
+	return w
+}
+
+// Write implements io.Writer.
+func (w *StreamWriter) Write(b []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		if n, err = w.conn.Write(b); err == nil {
+			return n, nil
+		}
+		w.conn.Close()
+		w.conn = nil
+		w.reconnect()
+	}
+	return w.spool.Write(b)
+}
+
+// reconnect attempts to connect to the collector in the background, with
+// exponential backoff, replaying the spool once it has. It's to be called
+// with the mutex held, while disconnected. Entries written out while
+// replaying are spooled, and replayed in turn.
+func (w *StreamWriter) reconnect() {
+	if w.closed {
+		return
+	}
+
+	go func() {
+		backoff := streamMinBackoff
+		for attempt := 0; ; attempt++ {
+			if attempt > 0 {
+				select {
+				case <-w.stopper:
+					return
+				case <-time.After(backoff):
+				}
+				if backoff *= 2; backoff > streamMaxBackoff {
+					backoff = streamMaxBackoff
+				}
+			}
+
+			c, err := net.DialTimeout("tcp", w.addr, streamDialTimeout)
+			if err != nil {
+				continue
+			}
+			conn := streamConn{Conn: c, timeout: w.writeTimeout}
+			if err := w.replay(conn); err != nil {
+				conn.Close()
+				if err == errStreamClosed {
+					return
+				}
+				continue
+			}
+			return
+		}
+	}()
+}
+
+// errStreamClosed is returned by replay if the writer is closed meanwhile.
+var errStreamClosed = errors.New("Stream writer closed")
+
+// replay writes out the spooled files to the provided connection, oldest
+// first, removing each once written out, and switches over to streaming
+// entries through it once there are none left. The mutex is only held to
+// take stock of the spooled files, not while writing them out, so entries
+// written out in the meantime are spooled to new files instead of blocking.
+func (w *StreamWriter) replay(conn net.Conn) error {
+	for {
+		w.mu.Lock()
+		if w.closed {
+			w.mu.Unlock()
+			return errStreamClosed
+		}
+		w.spool.Close()
+		files, err := ListLogFiles(w.spoolDir)
+		if err == nil && len(files) == 0 {
+			w.conn = conn
+		}
+		w.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		if err := w.replayFiles(conn, files); err != nil {
+			return err
+		}
+	}
+}
+
+// replayFiles writes out the provided spooled files to the provided
+// connection, in order, removing each once written out.
+func (w *StreamWriter) replayFiles(conn net.Conn, files []FileInfo) error {
+	for _, fi := range files {
+		path := filepath.Join(w.spoolDir, fi.Name)
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(conn, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		os.Remove(path)
+	}
+	return nil
+}
+
+// streamConn is a connection to the collector, bounding each write to it by
+// the provided timeout.
+type streamConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c streamConn) Write(b []byte) (int, error) {
+	c.SetWriteDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Write(b)
+}
+
+// Close closes the connection to the collector, if any, and stops
+// reconnecting. Entries written out thereafter are spooled.
+func (w *StreamWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.closed {
+		w.closed = true
+		close(w.stopper)
+	}
+	w.spool.Close()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
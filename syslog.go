@@ -0,0 +1,262 @@
+// Copyright 2018, Irfan Sharif.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog severities, as per RFC 5424.
+const (
+	syslogCrit    = 2
+	syslogErr     = 3
+	syslogWarning = 4
+	syslogInfo    = 6
+	syslogDebug   = 7
+)
+
+// syslogSeverity returns the syslog severity the provided mode maps to.
+// Entries without a header (think backtraces) are logged as informational.
+func syslogSeverity(m Mode) int {
+	switch m {
+	case FatalMode:
+		return syslogCrit
+	case ErrorMode:
+		return syslogErr
+	case WarnMode:
+		return syslogWarning
+	case DebugMode:
+		return syslogDebug
+	default:
+		return syslogInfo
+	}
+}
+
+// SyslogWriter is an io.Writer that forwards log entries to a syslog daemon,
+// see NewSyslogWriter.
+type SyslogWriter struct {
+	network, addr string
+	facility      int
+	tag           string
+	rfc3164       bool
+
+	mu      sync.Mutex
+	conn    net.Conn      // Current connection, nil if to be (re-)established
+	framing syslogFraming // How entries are delimited over conn
+}
+
+// syslogFraming is how entries are delimited over a connection to a syslog
+// daemon.
+type syslogFraming int
+
+const (
+	syslogDatagram       syslogFraming = iota // One entry per datagram
+	syslogOctetCounting                       // Length-prefixed, as per RFC 6587
+	syslogNonTransparent                      // Newline-terminated, as per RFC 6587
+)
+
+// syslogFramingFor returns how entries are to be delimited over the provided
+// network. Local syslog daemons listening on unix stream sockets expect
+// newline-terminated entries, octet counting is only used over TCP.
+func syslogFramingFor(network string) syslogFraming {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return syslogOctetCounting
+	case "unix":
+		return syslogNonTransparent
+	default:
+		return syslogDatagram
+	}
+}
+
+type syslogOption func(*SyslogWriter)
+
+// SyslogFacility configures the syslog facility entries are logged under, as
+// per RFC 5424 (for e.g. 1 for user-level messages, the default, or 16
+// through 23 for local0 through local7).
+func SyslogFacility(facility int) syslogOption {
+	return func(w *SyslogWriter) {
+		w.facility = facility
+	}
+}
+
+// SyslogTag configures the tag (or APP-NAME) entries are logged with,
+// defaulting to the program name.
+func SyslogTag(tag string) syslogOption {
+	return func(w *SyslogWriter) {
+		w.tag = tag
+	}
+}
+
+// SyslogRFC3164 configures entries to be framed as per the (legacy) BSD
+// syslog protocol described in RFC 3164, as opposed to RFC 5424.
+func SyslogRFC3164() syslogOption {
+	return func(w *SyslogWriter) {
+		w.rfc3164 = true
+	}
+}
+
+// syslogLocalAddrs are the unix sockets local syslog daemons conventionally
+// listen on.
+var syslogLocalAddrs = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// NewSyslogWriter returns an io.Writer that forwards each log entry written
+// out to it to the syslog daemon listening on the provided address. The
+// network is one of "unixgram", "unix", "udp" or "tcp"; if both the network
+// and address are empty, the local syslog daemon is connected to (through
+// /dev/log, or the like). Entries are framed as per RFC 5424 (unless
+// configured otherwise, see SyslogRFC3164) with the mode mapped to the syslog
+// severity, and the file name and line number preceding the message:
+//
+//	<11>1 2018-04-19T06:33:04.606396Z host program 1234 - - fname.go:42] message
+//
+// Over TCP connections, entries are delimited using octet counting, as per
+// RFC 6587; over unix stream connections they're newline-terminated instead
+// (non-transparent framing), as local syslog daemons expect, with newlines
+// within messages (think backtraces) escaped as \n (see MultiLineEscape).
+// The connection is re-established if a write fails, retrying the write
+// once.
+//
+// Like EntryDecoder, it expects to be written to by a Logger configured to
+// include the mode, date, time and file name in its headers (LstdFlags does,
+// for e.g.), each write corresponding to a single entry. Backtraces written
+// out for tracepoints (see SetTracePoint) are sent as part of the entry they
+// precede, following the message.
+func NewSyslogWriter(network, addr string, options ...syslogOption) (*SyslogWriter, error) {
+	w := &SyslogWriter{
+		network:  network,
+		addr:     addr,
+		facility: 1,
+		tag:      program,
+	}
+	for _, option := range options {
+		option(w)
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// connect (re-)establishes the connection to the syslog daemon.
+func (w *SyslogWriter) connect() error {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if w.network != "" || w.addr != "" {
+		conn, err := net.Dial(w.network, w.addr)
+		if err != nil {
+			return err
+		}
+		w.conn, w.framing = conn, syslogFramingFor(w.network)
+		return nil
+	}
+
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, addr := range syslogLocalAddrs {
+			conn, err := net.Dial(network, addr)
+			if err == nil {
+				w.conn, w.framing = conn, syslogFramingFor(network)
+				return nil
+			}
+		}
+	}
+	return errors.New("Unable to connect to local syslog daemon")
+}
+
+// Write implements io.Writer.
+func (w *SyslogWriter) Write(b []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e, err := decodeEntry(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if err = w.connect(); err != nil {
+				continue
+			}
+		}
+		if _, err = w.conn.Write(w.frame(e, w.framing)); err == nil {
+			return len(b), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	return 0, err
+}
+
+// frame formats the provided entry as per RFC 5424 (or RFC 3164), delimited
+// as specified.
+func (w *SyslogWriter) frame(e Entry, framing syslogFraming) []byte {
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	msg := e.messageWithStack()
+	if e.File != "" {
+		msg = fmt.Sprintf("%s:%d] %s", e.File, e.Line, msg)
+	}
+	if framing == syslogNonTransparent {
+		// Newlines delimit entries, so they can't occur within them.
+		msg = strings.Replace(msg, "\n", `\n`, -1)
+	}
+
+	var buf bytes.Buffer
+	pri := w.facility*8 + syslogSeverity(e.Mode)
+	if w.rfc3164 {
+		// RFC 3164 timestamps are in local time, without a time zone.
+		fmt.Fprintf(&buf, "<%d>%s %s %s[%d]: %s",
+			pri, t.Local().Format(time.Stamp), hostname, w.tag, pid, msg)
+	} else {
+		fmt.Fprintf(&buf, "<%d>1 %s %s %s %d - - %s",
+			pri, t.Format("2006-01-02T15:04:05.000000Z07:00"), hostname, w.tag, pid, msg)
+	}
+
+	switch framing {
+	case syslogOctetCounting:
+		return append([]byte(strconv.Itoa(buf.Len())+" "), buf.Bytes()...)
+	case syslogNonTransparent:
+		return append(buf.Bytes(), '\n')
+	default:
+		return buf.Bytes()
+	}
+}
+
+// Close closes the connection to the syslog daemon.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
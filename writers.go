@@ -74,10 +74,132 @@ func LogRotationWriter(dirname string, sizeThreshold int) io.Writer {
 	}
 }
 
+// Output is an io.Writer that writes out to rotating log files within a
+// directory (see LogRotationWriter), to standard error, or both, and can be
+// reconfigured to do so at runtime (see AdminOutput). It's safe for concurrent
+// use.
+type Output struct {
+	sync.Mutex
+	dir      string
+	toStderr bool
+	maxSize  int                // Size threshold for log files
+	rotation *logRotationWriter // Writer for dir, nil if dir is empty
+	terminal int32              // Whether writing out to a terminal exclusively, accessed atomically. See color.go
+}
+
+// NewOutput returns an Output writing out to rotating log files within the
+// specified directory (unless empty), thresholded at 50 MiB, and to standard
+// error if specified.
+func NewOutput(dir string, toStderr bool) *Output {
+	o := &Output{maxSize: 50 << 20 /* 50 MiB */}
+	o.SetDir(dir)
+	o.SetToStderr(toStderr)
+	return o
+}
+
+// Dir returns the directory log files are written out to, if any.
+func (o *Output) Dir() string {
+	o.Lock()
+	defer o.Unlock()
+	return o.dir
+}
+
+// SetDir configures the directory log files are written out to, closing out
+// the log file in the previous directory, if any. An empty dir stops writing
+// out log files altogether.
+func (o *Output) SetDir(dir string) {
+	o.Lock()
+	defer o.Unlock()
+	if dir == o.dir {
+		return
+	}
+	if o.rotation != nil {
+		o.rotation.Close()
+		o.rotation = nil
+	}
+	o.dir = dir
+	if dir != "" {
+		o.rotation = LogRotationWriter(dir, o.maxSize).(*logRotationWriter)
+	}
+	o.updateTerminal()
+}
+
+// MaxSize returns the size threshold, in bytes, for log files written out.
+func (o *Output) MaxSize() int {
+	o.Lock()
+	defer o.Unlock()
+	return o.maxSize
+}
+
+// SetMaxSize configures the size threshold, in bytes, for log files written
+// out (see LogRotationWriter), taking effect starting with the current log
+// file.
+func (o *Output) SetMaxSize(maxSize int) {
+	o.Lock()
+	defer o.Unlock()
+	o.maxSize = maxSize
+	if o.rotation != nil {
+		o.rotation.sizeThreshold = maxSize
+	}
+}
+
+// ToStderr returns whether or not logs are written out to standard error.
+func (o *Output) ToStderr() bool {
+	o.Lock()
+	defer o.Unlock()
+	return o.toStderr
+}
+
+// SetToStderr configures whether or not logs are written out to standard
+// error.
+func (o *Output) SetToStderr(toStderr bool) {
+	o.Lock()
+	defer o.Unlock()
+	o.toStderr = toStderr
+	o.updateTerminal()
+}
+
+// Write writes out to the configured log directory and standard error, if
+// configured to do either. Like with multiWriter, the smallest n and the last
+// non-nil error, if any, is returned.
+func (o *Output) Write(b []byte) (n int, err error) {
+	o.Lock()
+	defer o.Unlock()
+
+	n = len(b)
+	if o.rotation != nil {
+		nbytes, er := o.rotation.Write(b)
+		if nbytes < n {
+			n = nbytes
+		}
+		if er != nil {
+			err = er
+		}
+	}
+	if o.toStderr {
+		nbytes, er := os.Stderr.Write(b)
+		if nbytes < n {
+			n = nbytes
+		}
+		if er != nil {
+			err = er
+		}
+	}
+	return n, err
+}
+
+// entryWriter is implemented by writers making use of the fully qualified
+// file name of the logging statement each entry is written out by, as
+// opposed to the one included in the header (see Flags). See JournaldWriter.
+type entryWriter interface {
+	writeEntry(b []byte, file string) (n int, err error)
+}
+
 // SynchronizedWriter wraps an io.Writer with a mutex for concurrent access.
 func SynchronizedWriter(w io.Writer) io.Writer {
 	return &synchronizedWriter{
-		w: w,
+		w:      w,
+		helper: writerHelper(w),
 	}
 }
 
@@ -86,6 +208,7 @@ func MultiWriter(w io.Writer, ws ...io.Writer) io.Writer {
 	mw := &multiWriter{}
 	mw.ws = append(mw.ws, w)
 	mw.ws = append(mw.ws, ws...)
+	mw.helper = writerHelper(mw)
 	return mw
 }
 
@@ -111,8 +234,7 @@ type logRotationWriter struct {
 // our previous log file.
 func (r *logRotationWriter) Write(b []byte) (n int, err error) {
 	if r.currentFile == nil || (r.currentFileSize+len(b) > r.sizeThreshold) {
-		fname := generateLogFilename(time.Now())
-		f, err := os.Create(filepath.Join(r.dirname, fname))
+		fname, f, err := createLogFile(r.dirname, time.Now())
 		if err != nil {
 			return 0, err
 		}
@@ -128,12 +250,44 @@ func (r *logRotationWriter) Write(b []byte) (n int, err error) {
 	return n, err
 }
 
+// createLogFile creates a new log file within the provided directory, named
+// after the provided time (see generateLogFilename). Existing files are never
+// truncated; if one was already created within the same millisecond (think
+// rotations in quick succession), the new one is named as if created a
+// millisecond later, so that it's still ordered after it (see ListLogFiles).
+func createLogFile(dir string, t time.Time) (string, *os.File, error) {
+	for {
+		fname := generateLogFilename(t)
+		f, err := os.OpenFile(filepath.Join(dir, fname), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			t = t.Add(time.Millisecond)
+			continue
+		}
+		return fname, f, err
+	}
+}
+
+// Close closes the current log file, if any. Subsequent writes create a new
+// one.
+func (r *logRotationWriter) Close() error {
+	if r.currentFile == nil {
+		return nil
+	}
+	err := r.currentFile.Close()
+	r.currentFile = nil
+	return err
+}
+
 type synchronizedWriter struct {
 	sync.Mutex
-	w io.Writer
+	w      io.Writer
+	helper func() // Test helper marking for w, if any. See Writer
 }
 
 func (s *synchronizedWriter) Write(b []byte) (n int, err error) {
+	if s.helper != nil {
+		s.helper()
+	}
 	s.Lock()
 	n, err = s.w.Write(b)
 	s.Unlock()
@@ -141,13 +295,17 @@ func (s *synchronizedWriter) Write(b []byte) (n int, err error) {
 }
 
 type multiWriter struct {
-	ws []io.Writer
+	ws     []io.Writer
+	helper func() // Test helper marking for ws, if any. See Writer
 }
 
 // We do a best effort write on all the writers, but return (n, err)
 // conservatively. i.e. we return the smallest n across all the writers, and
 // the last non-nil error, if any.
 func (m *multiWriter) Write(b []byte) (n int, err error) {
+	if m.helper != nil {
+		m.helper()
+	}
 	n = len(b) // Optimistic estimation.
 	for _, w := range m.ws {
 		nbytes, er := w.Write(b)